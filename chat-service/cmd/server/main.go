@@ -15,6 +15,7 @@ import (
 	"github.com/chatmenow/chat-service/internal/model"
 	"github.com/chatmenow/chat-service/internal/repository"
 	"github.com/chatmenow/chat-service/internal/service"
+	"github.com/chatmenow/chat-service/internal/storage"
 	"github.com/chatmenow/chat-service/internal/websocket"
 	"gorm.io/gorm"
 )
@@ -23,16 +24,36 @@ import (
 func autoMigrate(db *gorm.DB) error {
 	log.Println("Running database migrations...")
 
+	if err := repository.BackfillMessageSeq(db); err != nil {
+		return err
+	}
+	if err := repository.DedupeMembers(db); err != nil {
+		return err
+	}
+
 	err := db.AutoMigrate(
 		&model.Conversation{},
 		&model.ConversationMember{},
 		&model.Message{},
+		&model.MessageEdit{},
+		&model.HiddenMessage{},
+		&model.MessageReaction{},
+		&model.MessageMention{},
+		&model.ConversationInvite{},
+		&model.JoinRequest{},
+		&model.ConversationSettings{},
+		&model.PinnedMessage{},
+		&model.Attachment{},
 	)
 
 	if err != nil {
 		return err
 	}
 
+	if err := db.Exec(repository.SearchIndexSQL).Error; err != nil {
+		return err
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
@@ -48,29 +69,74 @@ func main() {
 
 	// Initialize repositories
 	messageRepo := repository.NewMessageRepository(cfg.DB)
+	messageSearcher := repository.NewPostgresSearcher(cfg.DB)
 	conversationRepo := repository.NewConversationRepository(cfg.DB)
+	inviteRepo := repository.NewInviteRepository(cfg.DB)
+	attachmentRepo := repository.NewAttachmentRepository(cfg.DB)
 	redisClient := repository.NewRedisClient(cfg.RedisURL)
 	defer redisClient.Close()
 
 	// Initialize services
-	messageService := service.NewMessageService(messageRepo)
 	conversationService := service.NewConversationService(conversationRepo)
 	presenceService := service.NewPresenceService(redisClient)
+	messageService := service.NewMessageService(messageRepo, conversationService, presenceService, cfg.EditWindow, cfg.MaxPins)
+	searchService := service.NewSearchService(messageSearcher, conversationService)
+	inviteService := service.NewInviteService(inviteRepo, conversationService)
+	authorizer := service.NewAuthorizer(conversationService)
+
+	// Attachments go to S3-compatible storage or to local disk, which this
+	// server then serves under /files/
+	var blobStore storage.BlobStore
+	var localStore *storage.LocalStore
+	var err error
+	if cfg.StorageBackend == "s3" {
+		blobStore, err = storage.NewS3Store(cfg.S3)
+	} else {
+		localStore, err = storage.NewLocalStore(cfg.StorageDir, cfg.StoragePublicURL, cfg.StorageSigningKey)
+		blobStore = localStore
+	}
+	if err != nil {
+		log.Fatalf("Failed to initialize attachment storage: %v", err)
+	}
+	attachmentService := service.NewAttachmentService(attachmentRepo, blobStore, service.AttachmentLimits{
+		MaxSize:      cfg.AttachmentMaxSize,
+		AllowedTypes: cfg.AttachmentTypes,
+		URLTTL:       cfg.AttachmentURLTTL,
+	})
+
+	// Fan-out between replicas goes through Redis unless running a single instance
+	var broadcaster websocket.Broadcaster
+	if cfg.Broadcaster == "memory" {
+		broadcaster = websocket.NewMemoryBroadcaster()
+	} else {
+		broadcaster = websocket.NewRedisBroadcaster(redisClient)
+	}
+	defer broadcaster.Close()
 
-	hub := websocket.NewHub(messageService, presenceService)
+	hub := websocket.NewHub(broadcaster, messageService, conversationService, presenceService, authorizer)
 	go hub.Run()
 
-	h := handler.New(cfg, messageService, conversationService, hub)
+	h := handler.New(cfg, messageService, conversationService, searchService, inviteService, attachmentService, authorizer, hub)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", h.HealthCheck)
 	mux.HandleFunc("/ws", h.WebSocketHandler)
+	if localStore != nil {
+		// Downloads are authorized by the signature in the URL, not a JWT
+		mux.Handle("/files/", http.StripPrefix("/files", localStore))
+	}
 
 	// Protected routes - require JWT authentication
 	authMiddleware := middleware.JWTAuth(cfg.JWTSecret)
 	mux.Handle("/conversations", authMiddleware(http.HandlerFunc(h.ConversationsHandler)))
 	mux.Handle("/conversations/", authMiddleware(http.HandlerFunc(h.ConversationHandler)))
 	mux.Handle("/messages", authMiddleware(http.HandlerFunc(h.SendMessageHandler)))
+	mux.Handle("/messages/", authMiddleware(http.HandlerFunc(h.MessageHandler)))
+	mux.Handle("/invites/", authMiddleware(http.HandlerFunc(h.InviteHandler)))
+	mux.Handle("/attachments", authMiddleware(http.HandlerFunc(h.AttachmentsHandler)))
+	mux.Handle("/attachments/", authMiddleware(http.HandlerFunc(h.AttachmentHandler)))
+	mux.Handle("/me/mentions", authMiddleware(http.HandlerFunc(h.MentionsHandler)))
+	mux.Handle("/search/messages", authMiddleware(http.HandlerFunc(h.SearchMessagesHandler)))
 
 	// HTTP Server
 	srv := &http.Server{
@@ -1,23 +1,54 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/chatmenow/chat-service/internal/storage"
+	"golang.org/x/crypto/hkdf"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// defaultAttachmentTypes are the media types accepted for upload unless
+// ATTACHMENT_ALLOWED_TYPES says otherwise.
+var defaultAttachmentTypes = []string{
+	"image/*",
+	"video/*",
+	"audio/*",
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+}
+
 type Config struct {
 	Port        string
 	MongoURI    string
 	RedisURL    string
 	PostgresURL string
 	JWTSecret   string
-	DB          *gorm.DB
+	Broadcaster string // redis (default) or memory for a single instance
+	EditWindow  time.Duration
+	MaxPins     int // pinned messages per conversation, 0 = unlimited
+
+	StorageBackend    string // local (default) or s3
+	StorageDir        string // local backend: where blobs are written
+	StoragePublicURL  string // local backend: public URL of the /files/ handler
+	StorageSigningKey string // local backend: HMAC key for download URLs, derived from JWTSecret if unset
+	S3                storage.S3Config
+	AttachmentMaxSize int64 // bytes
+	AttachmentTypes   []string
+	AttachmentURLTTL  time.Duration
+
+	DB *gorm.DB
 }
 
 func Load() *Config {
@@ -27,6 +58,41 @@ func Load() *Config {
 		RedisURL:    getEnv("REDIS_URL"),
 		PostgresURL: getEnv("POSTGRES_URL"),
 		JWTSecret:   getEnv("JWT_SECRET"),
+		Broadcaster: getEnv("BROADCASTER"),
+		EditWindow:  getDuration("MESSAGE_EDIT_WINDOW", 15*time.Minute),
+		MaxPins:     getInt("MAX_PINNED_MESSAGES", 50),
+
+		StorageBackend:    getEnv("STORAGE_BACKEND"),
+		StorageDir:        getEnv("STORAGE_LOCAL_DIR"),
+		StoragePublicURL:  getEnv("STORAGE_PUBLIC_URL"),
+		StorageSigningKey: getEnv("STORAGE_SIGNING_KEY"),
+		S3: storage.S3Config{
+			Endpoint:  getEnv("S3_ENDPOINT"),
+			Region:    getEnv("S3_REGION"),
+			Bucket:    getEnv("S3_BUCKET"),
+			AccessKey: getEnv("S3_ACCESS_KEY"),
+			SecretKey: getEnv("S3_SECRET_KEY"),
+			PathStyle: getBool("S3_PATH_STYLE", false),
+		},
+		AttachmentMaxSize: int64(getInt("ATTACHMENT_MAX_SIZE", 25<<20)),
+		AttachmentTypes:   getList("ATTACHMENT_ALLOWED_TYPES", defaultAttachmentTypes),
+		AttachmentURLTTL:  getDuration("ATTACHMENT_URL_TTL", 5*time.Minute),
+	}
+
+	if cfg.StorageDir == "" {
+		cfg.StorageDir = "./data/attachments"
+	}
+	if cfg.StoragePublicURL == "" {
+		cfg.StoragePublicURL = "http://localhost:" + cfg.Port + "/files/"
+	}
+	if cfg.StorageBackend != "s3" && cfg.StorageSigningKey == "" {
+		// Never sign downloads with the JWT secret itself: a leaked download
+		// key must not be usable to mint tokens
+		if cfg.JWTSecret == "" {
+			log.Fatal("STORAGE_SIGNING_KEY is required for the local storage backend")
+		}
+		log.Println("Warning: STORAGE_SIGNING_KEY is not set, deriving the download signing key from JWT_SECRET")
+		cfg.StorageSigningKey = deriveKey(cfg.JWTSecret, "chat-service storage signing")
 	}
 
 	var err error
@@ -54,6 +120,7 @@ func initGORM(postgresURL string) (*gorm.DB, error) {
 		Logger:                 gormLogger,
 		SkipDefaultTransaction: true,
 		PrepareStmt:            true,
+		TranslateError:         true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -76,9 +143,79 @@ func initGORM(postgresURL string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// deriveKey derives an independent key for purpose from secret with HKDF-SHA256.
+func deriveKey(secret, purpose string) string {
+	key := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(purpose)), key); err != nil {
+		log.Fatalf("Failed to derive %s key: %v", purpose, err)
+	}
+	return hex.EncodeToString(key)
+}
+
 func getEnv(key string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return ""
 }
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value := getEnv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid duration for %s (%q), using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getInt(key string, fallback int) int {
+	value := getEnv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Warning: invalid number for %s (%q), using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getBool(key string, fallback bool) bool {
+	value := getEnv(key)
+	if value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid boolean for %s (%q), using %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getList reads a comma-separated list, ignoring blank entries.
+func getList(key string, fallback []string) []string {
+	value := getEnv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestDeriveKey(t *testing.T) {
+	key := deriveKey("jwt-secret", "storage")
+	if key == "" || key == "jwt-secret" {
+		t.Fatalf("deriveKey = %q", key)
+	}
+	if len(key) != 64 {
+		t.Errorf("deriveKey is %d hex characters, want 64", len(key))
+	}
+	if deriveKey("jwt-secret", "storage") != key {
+		t.Error("deriveKey is not deterministic")
+	}
+	if deriveKey("jwt-secret", "other") == key {
+		t.Error("different purposes derive the same key")
+	}
+	if deriveKey("other-secret", "storage") == key {
+		t.Error("different secrets derive the same key")
+	}
+}
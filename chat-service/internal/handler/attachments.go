@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/chatmenow/chat-service/internal/middleware"
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// multipartOverhead is room for the form's boundaries and other fields on
+	// top of the file itself.
+	multipartOverhead = 1 << 20
+	// multipartMemory is how much of an upload is buffered in memory before
+	// spilling to a temporary file.
+	multipartMemory = 8 << 20
+)
+
+// AttachmentsHandler serves POST /attachments, a multipart form with the
+// target `conversationId` and the `file` itself. The upload can then be sent
+// by passing its ID in a message's attachmentIds.
+func (h *Handler) AttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.attachmentService.MaxSize()+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Attachment exceeds the maximum size", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	conversationID, err := uuid.Parse(r.FormValue("conversationId"))
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if !h.authorize(w, r, conversationID, userID, model.PermPost) {
+		return
+	}
+
+	attachment, err := h.attachmentService.Upload(r.Context(), conversationID, userID,
+		header.Filename, header.Header.Get("Content-Type"), file, header.Size)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// AttachmentHandler serves GET /attachments/{id}: the attachment's metadata
+// and a short-lived download URL.
+func (h *Handler) AttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/attachments/")
+	if path == "" || strings.Contains(path, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	attachmentID, err := uuid.Parse(path)
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.attachmentService.Get(r.Context(), attachmentID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(attachment)
+}
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chatmenow/chat-service/internal/config"
 	"github.com/chatmenow/chat-service/internal/middleware"
@@ -14,12 +16,17 @@ import (
 	"github.com/chatmenow/chat-service/internal/websocket"
 	"github.com/google/uuid"
 	ws "github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 type Handler struct {
 	config              *config.Config
 	messageService      *service.MessageService
 	conversationService *service.ConversationService
+	searchService       *service.SearchService
+	inviteService       *service.InviteService
+	attachmentService   *service.AttachmentService
+	authorizer          *service.Authorizer
 	hub                 *websocket.Hub
 	upgrader            ws.Upgrader
 }
@@ -28,12 +35,20 @@ func New(
 	cfg *config.Config,
 	messageService *service.MessageService,
 	conversationService *service.ConversationService,
+	searchService *service.SearchService,
+	inviteService *service.InviteService,
+	attachmentService *service.AttachmentService,
+	authorizer *service.Authorizer,
 	hub *websocket.Hub,
 ) *Handler {
 	return &Handler{
 		config:              cfg,
 		messageService:      messageService,
 		conversationService: conversationService,
+		searchService:       searchService,
+		inviteService:       inviteService,
+		attachmentService:   attachmentService,
+		authorizer:          authorizer,
 		hub:                 hub,
 		upgrader: ws.Upgrader{
 			ReadBufferSize:  1024,
@@ -84,8 +99,8 @@ func (h *Handler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create client
-	client := websocket.NewClient(h.hub, conn, claims.Sub)
+	// Create client; each device of the same user gets its own connection
+	client := websocket.NewClient(h.hub, conn, claims.Sub, r.URL.Query().Get("deviceId"))
 	h.hub.RegisterClient(client)
 
 	// Start pumps
@@ -118,7 +133,10 @@ func (h *Handler) getConversations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conversations, err := h.conversationService.GetByUser(r.Context(), userID)
+	// ?archived=true lists archived conversations instead of the inbox
+	archived := r.URL.Query().Get("archived") == "true"
+
+	conversations, err := h.conversationService.GetByUser(r.Context(), userID, archived)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -148,13 +166,17 @@ func (h *Handler) createConversation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conversation, err := h.conversationService.Create(r.Context(), &req, userID)
+	conversation, created, err := h.conversationService.Create(r.Context(), &req, userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// An existing direct conversation between the same two users is returned as is
 	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
 	json.NewEncoder(w).Encode(conversation)
 }
 
@@ -169,15 +191,338 @@ func (h *Handler) ConversationHandler(w http.ResponseWriter, r *http.Request) {
 
 	conversationID := parts[0]
 
-	if len(parts) >= 2 && parts[1] == "messages" {
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		h.getConversation(w, r, conversationID)
+	case len(parts) == 1 && r.Method == http.MethodPatch:
+		h.updateConversation(w, r, conversationID)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		h.deleteConversation(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "messages" && r.Method == http.MethodGet:
 		h.getMessages(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "read" && r.Method == http.MethodPost:
+		h.markRead(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "members" && r.Method == http.MethodGet:
+		h.getMembers(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "members" && r.Method == http.MethodPost:
+		h.addMember(w, r, conversationID)
+	case len(parts) == 3 && parts[1] == "members" && r.Method == http.MethodPatch:
+		h.updateMember(w, r, conversationID, parts[2])
+	case len(parts) == 3 && parts[1] == "members" && r.Method == http.MethodDelete:
+		h.removeMember(w, r, conversationID, parts[2])
+	case len(parts) == 2 && parts[1] == "leave" && r.Method == http.MethodPost:
+		h.removeMember(w, r, conversationID, "")
+	case len(parts) == 2 && parts[1] == "permissions" && r.Method == http.MethodGet:
+		h.getPermissions(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "permissions" && r.Method == http.MethodPatch:
+		h.updatePermissions(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "pins" && r.Method == http.MethodGet:
+		h.getPins(w, r, conversationID)
+	case len(parts) == 3 && parts[1] == "pins" && r.Method == http.MethodPost:
+		h.pinMessage(w, r, conversationID, parts[2])
+	case len(parts) == 3 && parts[1] == "pins" && r.Method == http.MethodDelete:
+		h.unpinMessage(w, r, conversationID, parts[2])
+	case len(parts) == 2 && parts[1] == "settings" && r.Method == http.MethodGet:
+		h.getSettings(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "settings" && r.Method == http.MethodPatch:
+		h.updateSettings(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "invites" && r.Method == http.MethodGet:
+		h.getInvites(w, r, conversationID)
+	case len(parts) == 2 && parts[1] == "invites" && r.Method == http.MethodPost:
+		h.createInvite(w, r, conversationID)
+	case len(parts) == 3 && parts[1] == "invites" && r.Method == http.MethodDelete:
+		h.revokeInvite(w, r, conversationID, parts[2])
+	case len(parts) == 2 && parts[1] == "join-requests" && r.Method == http.MethodGet:
+		h.getJoinRequests(w, r, conversationID)
+	case len(parts) == 4 && parts[1] == "join-requests" && parts[3] == "approve" && r.Method == http.MethodPost:
+		h.decideJoinRequest(w, r, conversationID, parts[2], true)
+	case len(parts) == 4 && parts[1] == "join-requests" && parts[3] == "reject" && r.Method == http.MethodPost:
+		h.decideJoinRequest(w, r, conversationID, parts[2], false)
+	case len(parts) <= 2,
+		len(parts) == 3 && (parts[1] == "members" || parts[1] == "invites" || parts[1] == "pins"),
+		len(parts) == 4 && parts[1] == "join-requests" && (parts[3] == "approve" || parts[3] == "reject"):
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// getConversation returns the conversation with its members, including each
+// member's read pointer.
+func (h *Handler) getConversation(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.requireMember(w, r, conversationID, userID) {
+		return
+	}
+
+	conversation, err := h.conversationService.GetByID(r.Context(), conversationID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// updateConversation changes the name, avatar and/or description. It requires
+// the edit_info permission.
+func (h *Handler) updateConversation(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.UpdateConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermEditInfo) {
+		return
+	}
+
+	conv, err := h.conversationService.UpdateDetails(r.Context(), conversationID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.hub.SendToUsers(conversationIDStr, memberIDs(conv.Members), websocket.ConversationUpdatedEvent(conv, userID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conv)
+}
+
+// deleteConversation soft-deletes the conversation with its members and
+// messages. It requires the delete_conversation permission.
+func (h *Handler) deleteConversation(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermDeleteConversation) {
+		return
+	}
+
+	members, err := h.conversationService.Delete(r.Context(), conversationID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.hub.CloseConversation(conversationIDStr, memberIDs(members), websocket.ConversationDeletedEvent(conversationID, userID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPermissions returns the conversation's effective permission policy: the
+// minimum role required for each action.
+func (h *Handler) getPermissions(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.authorizer.Policy(r.Context(), conversationID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// updatePermissions overrides the minimum role for some actions. It requires
+// the manage_permissions permission.
+func (h *Handler) updatePermissions(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.UpdatePermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermManagePermissions) {
+		return
+	}
+
+	conv, err := h.conversationService.UpdatePermissions(r.Context(), conversationID, req.Permissions)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.hub.BroadcastPolicyChange(conversationIDStr, memberIDs(conv.Members), websocket.ConversationUpdatedEvent(conv, userID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conv.EffectivePolicy())
+}
+
+// memberIDs returns the user IDs of members as strings, for SendToUsers.
+func memberIDs(members []model.ConversationMember) []string {
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID.String()
+	}
+	return ids
+}
+
+func (h *Handler) markRead(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !h.requireMember(w, r, conversationID, userID) {
+		return
+	}
+
+	receipt, err := h.conversationService.MarkRead(r.Context(), conversationID, userID, req.MessageID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Already read up to this point (or a later message)
+	if receipt == nil {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	http.Error(w, "Not found", http.StatusNotFound)
+	h.hub.BroadcastToConversation(conversationIDStr, websocket.ReadReceiptEvent(receipt), nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// requireMember writes a 403 and returns false if the user is not a member of the conversation.
+func (h *Handler) requireMember(w http.ResponseWriter, r *http.Request, conversationID, userID uuid.UUID) bool {
+	if err := h.conversationService.RequireMember(r.Context(), conversationID, userID); err != nil {
+		writeError(w, err)
+		return false
+	}
+	return true
+}
+
+// authorize writes a 403 and returns false if the user's role in the
+// conversation doesn't grant perm.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, conversationID, userID uuid.UUID, perm model.Permission) bool {
+	if err := h.authorizer.Authorize(r.Context(), conversationID, userID, perm); err != nil {
+		writeError(w, err)
+		return false
+	}
+	return true
 }
 
 func (h *Handler) getMessages(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
 	// Parse conversation ID
 	conversationID, err := uuid.Parse(conversationIDStr)
 	if err != nil {
@@ -185,28 +530,68 @@ func (h *Handler) getMessages(w http.ResponseWriter, r *http.Request, conversati
 		return
 	}
 
-	// Get query parameters for pagination
-	limit := 50
-	offset := 0
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	if !h.requireMember(w, r, conversationID, userID) {
+		return
 	}
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
+
+	query, err := parseMessageQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	messages, err := h.messageService.GetMessages(r.Context(), conversationID, limit, offset)
+	page, err := h.messageService.GetMessages(r.Context(), conversationID, userID, query)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(messages)
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseMessageQuery reads ?limit= and at most one of ?before=, ?after= (opaque
+// cursors) or ?around= (message ID) from the request.
+func parseMessageQuery(r *http.Request) (model.MessageQuery, error) {
+	var query model.MessageQuery
+	q := r.URL.Query()
+
+	if limitStr := q.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			query.Limit = l
+		}
+	}
+
+	set := 0
+	for _, key := range []string{"before", "after", "around"} {
+		if q.Get(key) != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return query, errors.New("only one of before, after or around may be given")
+	}
+
+	var err error
+	if before := q.Get("before"); before != "" {
+		if query.Before, err = model.DecodeMessageCursor(before); err != nil {
+			return query, err
+		}
+	}
+	if after := q.Get("after"); after != "" {
+		if query.After, err = model.DecodeMessageCursor(after); err != nil {
+			return query, err
+		}
+	}
+	if around := q.Get("around"); around != "" {
+		id, err := uuid.Parse(around)
+		if err != nil {
+			return query, errors.New("invalid around message ID")
+		}
+		query.Around = &id
+	}
+
+	return query, nil
 }
 
 func (h *Handler) SendMessageHandler(w http.ResponseWriter, r *http.Request) {
@@ -234,32 +619,509 @@ func (h *Handler) SendMessageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Type == "" {
+		req.Type = "text"
+	}
+	if !model.IsUserMessageType(req.Type) {
+		http.Error(w, "Invalid message type", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, req.ConversationID, userID, model.PermPost) {
+		return
+	}
+
 	msg := &model.Message{
 		ConversationID: req.ConversationID,
 		SenderID:       userID,
 		Content:        req.Content,
 		Type:           req.Type,
 		Metadata:       req.Metadata,
+		ReplyToID:      req.ReplyToID,
+		ThreadRootID:   req.ThreadRootID,
+		AttachmentIDs:  req.AttachmentIDs,
+	}
+	if req.ClientMsgID != "" {
+		msg.ClientMsgID = &req.ClientMsgID
 	}
 
-	if err := h.messageService.Create(r.Context(), msg); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	created, err := h.messageService.Create(r.Context(), msg)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	// Broadcast via WebSocket (use string representation for hub)
-	h.hub.BroadcastToConversation(req.ConversationID.String(), map[string]interface{}{
-		"type": "new_message",
-		"payload": map[string]interface{}{
-			"id":             msg.ID,
-			"conversationId": msg.ConversationID,
-			"senderId":       msg.SenderID,
-			"content":        msg.Content,
-			"type":           msg.Type,
-			"createdAt":      msg.CreatedAt,
-		},
-	}, nil)
+	// Broadcast via WebSocket (use string representation for hub); a retried
+	// send returns the stored message and was already broadcast
+	if created {
+		h.hub.PublishMessage(r.Context(), msg)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(msg)
 }
+
+// MessageHandler serves /messages/{id} and its sub-resources.
+func (h *Handler) MessageHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/messages/")
+	parts := strings.Split(path, "/")
+
+	messageID, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPatch:
+		h.editMessage(w, r, messageID)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		h.deleteMessage(w, r, messageID)
+	case len(parts) == 2 && parts[1] == "edits" && r.Method == http.MethodGet:
+		h.getMessageEdits(w, r, messageID)
+	case len(parts) == 2 && parts[1] == "thread" && r.Method == http.MethodGet:
+		h.getThread(w, r, messageID)
+	case len(parts) == 2 && parts[1] == "forward" && r.Method == http.MethodPost:
+		h.forwardMessage(w, r, messageID)
+	case len(parts) == 2 && parts[1] == "reactions" && r.Method == http.MethodPost:
+		h.react(w, r, messageID, true)
+	case len(parts) == 2 && parts[1] == "reactions" && r.Method == http.MethodDelete:
+		h.react(w, r, messageID, false)
+	case len(parts) <= 2:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// loadMessage fetches a message and checks the caller can see its conversation.
+func (h *Handler) loadMessage(w http.ResponseWriter, r *http.Request, messageID, userID uuid.UUID) (*model.Message, bool) {
+	msg, err := h.messageService.GetByID(r.Context(), messageID)
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+
+	if !h.requireMember(w, r, msg.ConversationID, userID) {
+		return nil, false
+	}
+
+	return msg, true
+}
+
+func (h *Handler) editMessage(w http.ResponseWriter, r *http.Request, messageID uuid.UUID) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	msg, ok := h.loadMessage(w, r, messageID, userID)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, msg.ConversationID, userID, model.PermPost) {
+		return
+	}
+
+	if err := h.messageService.Edit(r.Context(), msg, userID, req.Content); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.hub.BroadcastToConversation(msg.ConversationID.String(), websocket.MessageUpdatedEvent(msg), nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+func (h *Handler) deleteMessage(w http.ResponseWriter, r *http.Request, messageID uuid.UUID) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = model.DeleteScopeMe
+	}
+
+	msg, err := h.messageService.GetByID(r.Context(), messageID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.authorizer.AuthorizeDelete(r.Context(), msg, userID, scope); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.messageService.Delete(r.Context(), msg, userID, scope); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if scope == model.DeleteScopeEveryone {
+		h.hub.PublishDeletion(r.Context(), msg, userID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) getMessageEdits(w http.ResponseWriter, r *http.Request, messageID uuid.UUID) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.loadMessage(w, r, messageID, userID); !ok {
+		return
+	}
+
+	edits, err := h.messageService.GetEdits(r.Context(), messageID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(edits)
+}
+
+// getThread returns the thread root and a page of its replies. It accepts the
+// same pagination parameters as getMessages.
+func (h *Handler) getThread(w http.ResponseWriter, r *http.Request, messageID uuid.UUID) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseMessageQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg, ok := h.loadMessage(w, r, messageID, userID)
+	if !ok {
+		return
+	}
+
+	page, err := h.messageService.GetThread(r.Context(), msg, userID, query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// react adds or removes the caller's reaction. The emoji comes from the JSON
+// body, or for DELETE optionally from the ?emoji= query parameter.
+func (h *Handler) react(w http.ResponseWriter, r *http.Request, messageID uuid.UUID, add bool) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.ReactionRequest
+	req.Emoji = r.URL.Query().Get("emoji")
+	if req.Emoji == "" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	msg, ok := h.loadMessage(w, r, messageID, userID)
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, msg.ConversationID, userID, model.PermReact) {
+		return
+	}
+
+	changed, reactions, err := h.messageService.React(r.Context(), msg, userID, req.Emoji, add)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	event := websocket.ReactionUpdatedEvent(msg, userID, req.Emoji, add, reactions)
+	if changed {
+		h.hub.BroadcastToConversation(msg.ConversationID.String(), event, nil)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event.Payload)
+}
+
+// forwardMessage copies a message the caller can see into each of the target
+// conversations where they may post. Targets they can't post in are reported
+// under failed instead of failing the whole request.
+func (h *Handler) forwardMessage(w http.ResponseWriter, r *http.Request, messageID uuid.UUID) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.ForwardMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	targets, err := service.ForwardTargets(req.ConversationIDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	msg, ok := h.loadMessage(w, r, messageID, userID)
+	if !ok {
+		return
+	}
+
+	var failed []model.ForwardFailure
+	allowed := make([]uuid.UUID, 0, len(targets))
+	for _, conversationID := range targets {
+		if err := h.authorizer.Authorize(r.Context(), conversationID, userID, model.PermPost); err != nil {
+			failed = append(failed, model.ForwardFailure{ConversationID: conversationID, Error: err.Error()})
+			continue
+		}
+		allowed = append(allowed, conversationID)
+	}
+
+	result, err := h.messageService.Forward(r.Context(), msg, userID, allowed)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	result.Failed = append(failed, result.Failed...)
+
+	for i := range result.Messages {
+		copied := &result.Messages[i]
+		h.hub.BroadcastToConversation(copied.ConversationID.String(), websocket.NewMessageEvent(copied), nil)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// MentionsHandler serves GET /me/mentions, the caller's mentions inbox, newest
+// first. Pass nextCursor back as ?before= to load older mentions.
+func (h *Handler) MentionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseMessageQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.After != nil || query.Around != nil {
+		http.Error(w, "Only before is supported", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.messageService.GetMentions(r.Context(), userID, query.Before, query.Limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// SearchMessagesHandler serves GET /search/messages. q is required; the
+// conversationId, senderId, from, to (RFC 3339) and type filters are optional.
+func (h *Handler) SearchMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	query := model.SearchQuery{
+		UserID: userID,
+		Query:  q.Get("q"),
+		Type:   q.Get("type"),
+	}
+
+	if limitStr := q.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			query.Limit = l
+		}
+	}
+	if s := q.Get("conversationId"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+			return
+		}
+		query.ConversationID = &id
+	}
+	if s := q.Get("senderId"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			http.Error(w, "Invalid sender ID", http.StatusBadRequest)
+			return
+		}
+		query.SenderID = &id
+	}
+	if s := q.Get("from"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid from time", http.StatusBadRequest)
+			return
+		}
+		query.From = &t
+	}
+	if s := q.Get("to"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid to time", http.StatusBadRequest)
+			return
+		}
+		query.To = &t
+	}
+	if s := q.Get("before"); s != "" {
+		if query.Before, err = model.DecodeMessageCursor(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.searchService.Search(r.Context(), query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// writeError maps service-layer errors onto HTTP status codes.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrNotMember),
+		errors.Is(err, service.ErrNotSender),
+		errors.Is(err, service.ErrEditWindowExpired),
+		errors.Is(err, service.ErrForbidden),
+		errors.Is(err, service.ErrSystemMessage):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrEmptyContent),
+		errors.Is(err, service.ErrInvalidScope),
+		errors.Is(err, service.ErrInvalidClientMsgID),
+		errors.Is(err, service.ErrInvalidEmoji),
+		errors.Is(err, service.ErrInvalidReference),
+		errors.Is(err, service.ErrMentionNotMember),
+		errors.Is(err, service.ErrEmptyQuery),
+		errors.Is(err, service.ErrInvalidRole),
+		errors.Is(err, service.ErrDirectConversation),
+		errors.Is(err, service.ErrFieldTooLong),
+		errors.Is(err, service.ErrInvalidPermission),
+		errors.Is(err, service.ErrInvalidInvite),
+		errors.Is(err, service.ErrInvalidSettings),
+		errors.Is(err, service.ErrInvalidForward),
+		errors.Is(err, service.ErrCannotForward),
+		errors.Is(err, service.ErrCannotPin),
+		errors.Is(err, service.ErrInvalidAttachment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, service.ErrUnsupportedType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, service.ErrAlreadyMember),
+		errors.Is(err, service.ErrOwnerMustTransfer),
+		errors.Is(err, service.ErrRequestDecided),
+		errors.Is(err, service.ErrTooManyPins),
+		errors.Is(err, service.ErrTooManyReactions):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrInviteUnavailable):
+		http.Error(w, err.Error(), http.StatusGone)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/chatmenow/chat-service/internal/middleware"
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/websocket"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) createInvite(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	// Every field is optional, so an empty body is fine
+	var req model.CreateInviteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermAddMembers) {
+		return
+	}
+
+	invite, err := h.inviteService.Create(r.Context(), conversationID, userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+func (h *Handler) getInvites(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermAddMembers) {
+		return
+	}
+
+	invites, err := h.inviteService.List(r.Context(), conversationID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invites)
+}
+
+func (h *Handler) revokeInvite(w http.ResponseWriter, r *http.Request, conversationIDStr, inviteIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	inviteID, err := uuid.Parse(inviteIDStr)
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermAddMembers) {
+		return
+	}
+
+	if err := h.inviteService.Revoke(r.Context(), conversationID, inviteID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) getJoinRequests(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermAddMembers) {
+		return
+	}
+
+	reqs, err := h.inviteService.PendingRequests(r.Context(), conversationID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reqs)
+}
+
+// decideJoinRequest serves POST /conversations/{id}/join-requests/{requestId}/approve
+// and .../reject.
+func (h *Handler) decideJoinRequest(w http.ResponseWriter, r *http.Request, conversationIDStr, requestIDStr string, approve bool) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	requestID, err := uuid.Parse(requestIDStr)
+	if err != nil {
+		http.Error(w, "Invalid join request ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermAddMembers) {
+		return
+	}
+
+	if !approve {
+		req, err := h.inviteService.Reject(r.Context(), conversationID, requestID, userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(req)
+		return
+	}
+
+	member, err := h.inviteService.Approve(r.Context(), conversationID, requestID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.postSystemMessage(r, conversationID, userID, model.SystemMemberAdded, map[string]interface{}{
+		"userId": member.UserID,
+		"role":   member.Role,
+	})
+	h.hub.BroadcastMemberChange(conversationIDStr, member.UserID.String(), websocket.MemberAddedEvent(member, userID), false)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// InviteHandler serves POST /invites/{token}/accept. The caller joins right
+// away (201 with the membership) or, if the invite requires approval, gets a
+// pending join request (202).
+func (h *Handler) InviteHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/invites/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "accept" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.inviteService.Accept(r.Context(), parts[0], userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Member == nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	member := resp.Member
+	h.postSystemMessage(r, member.ConversationID, userID, model.SystemMemberJoined, map[string]interface{}{
+		"userId": member.UserID,
+		"role":   member.Role,
+	})
+	h.hub.BroadcastMemberChange(member.ConversationID.String(), member.UserID.String(), websocket.MemberAddedEvent(member, userID), false)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
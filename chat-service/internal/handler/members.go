@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/chatmenow/chat-service/internal/middleware"
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/websocket"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) getMembers(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.requireMember(w, r, conversationID, userID) {
+		return
+	}
+
+	members, err := h.conversationService.GetMembers(r.Context(), conversationID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+func (h *Handler) addMember(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == uuid.Nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = model.RoleMember
+	}
+	if err := h.authorizer.AuthorizeAdd(r.Context(), conversationID, userID, req.Role); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	member, err := h.conversationService.AddGroupMember(r.Context(), conversationID, req.UserID, req.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.postSystemMessage(r, conversationID, userID, model.SystemMemberAdded, map[string]interface{}{
+		"userId": member.UserID,
+		"role":   member.Role,
+	})
+	h.hub.BroadcastMemberChange(conversationIDStr, member.UserID.String(), websocket.MemberAddedEvent(member, userID), false)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+func (h *Handler) updateMember(w http.ResponseWriter, r *http.Request, conversationIDStr, memberIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := uuid.Parse(memberIDStr)
+	if err != nil {
+		http.Error(w, "Invalid member ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authorizer.AuthorizeRoleChange(r.Context(), conversationID, userID, memberID, req.Role); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	changed, err := h.conversationService.ChangeRole(r.Context(), conversationID, userID, memberID, req.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// An ownership transfer also demotes the caller
+	for _, member := range changed {
+		h.postSystemMessage(r, conversationID, userID, model.SystemRoleChanged, map[string]interface{}{
+			"userId": member.UserID,
+			"role":   member.Role,
+		})
+		h.hub.BroadcastMemberChange(conversationIDStr, member.UserID.String(), websocket.MemberUpdatedEvent(member, userID), false)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changed[0])
+}
+
+// removeMember serves DELETE /conversations/{id}/members/{userId}; leave is
+// the same operation with the caller as the member.
+func (h *Handler) removeMember(w http.ResponseWriter, r *http.Request, conversationIDStr, memberIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	memberID := userID
+	if memberIDStr != "" {
+		if memberID, err = uuid.Parse(memberIDStr); err != nil {
+			http.Error(w, "Invalid member ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Anyone may leave; removing someone else needs remove_members and a higher role
+	if memberID != userID {
+		if err := h.authorizer.AuthorizeOver(r.Context(), conversationID, userID, memberID, model.PermRemoveMembers); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	if err := h.conversationService.RemoveGroupMember(r.Context(), conversationID, memberID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	action := model.SystemMemberRemoved
+	if memberID == userID {
+		action = model.SystemMemberLeft
+	}
+	h.postSystemMessage(r, conversationID, userID, action, map[string]interface{}{
+		"userId": memberID,
+	})
+	h.hub.BroadcastMemberChange(conversationIDStr, memberID.String(), websocket.MemberRemovedEvent(conversationID, memberID, userID), true)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postSystemMessage records a change, such as a membership change or a pin,
+// in the message stream. The change itself has already been applied, so
+// failures are only logged.
+func (h *Handler) postSystemMessage(r *http.Request, conversationID, actorID uuid.UUID, action string, details map[string]interface{}) {
+	msg, err := h.messageService.PostSystemMessage(r.Context(), conversationID, actorID, action, details)
+	if err != nil {
+		log.Printf("Error posting %s system message to %s: %v", action, conversationID, err)
+		return
+	}
+	h.hub.PublishMessage(r.Context(), msg)
+}
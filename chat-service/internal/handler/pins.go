@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/chatmenow/chat-service/internal/middleware"
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/websocket"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) getPins(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.requireMember(w, r, conversationID, userID) {
+		return
+	}
+
+	pins, err := h.messageService.GetPins(r.Context(), conversationID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pins)
+}
+
+// pinMessage pins a message of the conversation. It requires the pin
+// permission; pinning an already pinned message returns the existing pin.
+func (h *Handler) pinMessage(w http.ResponseWriter, r *http.Request, conversationIDStr, messageIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	messageID, err := uuid.Parse(messageIDStr)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermPin) {
+		return
+	}
+
+	pin, added, err := h.messageService.Pin(r.Context(), conversationID, messageID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !added {
+		json.NewEncoder(w).Encode(pin)
+		return
+	}
+
+	h.postSystemMessage(r, conversationID, userID, model.SystemMessagePinned, map[string]interface{}{
+		"messageId": messageID,
+	})
+	h.hub.BroadcastToConversation(conversationIDStr, websocket.MessagePinnedEvent(pin), nil)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pin)
+}
+
+// unpinMessage removes a pin. It requires the pin permission.
+func (h *Handler) unpinMessage(w http.ResponseWriter, r *http.Request, conversationIDStr, messageIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	messageID, err := uuid.Parse(messageIDStr)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, conversationID, userID, model.PermPin) {
+		return
+	}
+
+	if err := h.messageService.Unpin(r.Context(), conversationID, messageID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.postSystemMessage(r, conversationID, userID, model.SystemMessageUnpinned, map[string]interface{}{
+		"messageId": messageID,
+	})
+	h.hub.BroadcastToConversation(conversationIDStr, websocket.MessageUnpinnedEvent(conversationID, messageID, userID), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
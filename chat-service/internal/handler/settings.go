@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/chatmenow/chat-service/internal/middleware"
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/websocket"
+	"github.com/google/uuid"
+)
+
+// getSettings returns the caller's own settings for the conversation.
+func (h *Handler) getSettings(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.conversationService.GetSettings(r.Context(), conversationID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// updateSettings changes the caller's settings. Their other connections get
+// settings_updated so every device stays in sync.
+func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request, conversationIDStr string) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(user.Sub)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.UpdateSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.conversationService.UpdateSettings(r.Context(), conversationID, userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.hub.SendToUsers(conversationIDStr, []string{user.Sub}, websocket.SettingsUpdatedEvent(settings))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
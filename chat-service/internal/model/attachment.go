@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment is a file uploaded to a conversation. It belongs to its uploader
+// until a message references it, after which anyone who can see a message
+// referencing it can download it through a short-lived signed URL.
+type Attachment struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ConversationID uuid.UUID  `json:"conversationId" gorm:"type:uuid;not null;index"`
+	UploaderID     uuid.UUID  `json:"uploaderId" gorm:"type:uuid;not null;index"`
+	MessageID      *uuid.UUID `json:"messageId,omitempty" gorm:"type:uuid;index"` // set once sent
+	FileName       string     `json:"fileName" gorm:"type:varchar(255);not null"`
+	ContentType    string     `json:"contentType" gorm:"type:varchar(255);not null"`
+	Size           int64      `json:"size" gorm:"not null"`
+	StorageKey     string     `json:"-" gorm:"type:varchar(512);not null"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	URL            string     `json:"url,omitempty" gorm:"-"` // signed download URL
+	URLExpiresAt   *time.Time `json:"urlExpiresAt,omitempty" gorm:"-"`
+}
+
+func (Attachment) TableName() string {
+	return "attachments"
+}
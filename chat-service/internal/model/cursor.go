@@ -0,0 +1,83 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// MessageCursor is a position in a conversation's history, ordered by
+// (created_at, id). Clients only ever see it in its opaque encoded form.
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func CursorFor(msg *Message) *MessageCursor {
+	return &MessageCursor{CreatedAt: msg.CreatedAt, ID: msg.ID}
+}
+
+func (c *MessageCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeMessageCursor(s string) (*MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &MessageCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// MessageQuery selects one page of history. At most one of Before, After and
+// Around is set; with none, the latest page is returned.
+type MessageQuery struct {
+	Before *MessageCursor
+	After  *MessageCursor
+	Around *uuid.UUID
+	Limit  int
+}
+
+// MessagePage is a page of history in chronological order. NextCursor loads
+// older messages (pass it as `before`), PrevCursor loads newer ones (pass it
+// as `after`); each is empty when there is nothing more in that direction.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+	PrevCursor string    `json:"prevCursor,omitempty"`
+}
+
+// ThreadPage is a page of replies to a thread root, paginated like MessagePage.
+type ThreadPage struct {
+	Root *Message `json:"root"`
+	MessagePage
+}
+
+// MentionPage is a page of a user's mentions inbox, newest first. NextCursor
+// loads older mentions (pass it as `before`).
+type MentionPage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMessageCursorRoundTrip(t *testing.T) {
+	loc := time.FixedZone("UTC+7", 7*60*60)
+	want := &MessageCursor{
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 123456789, loc),
+		ID:        uuid.New(),
+	}
+
+	got, err := DecodeMessageCursor(want.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeMessageCursorRejectsGarbage(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	for name, s := range map[string]string{
+		"empty":        "",
+		"not base64":   "!!!",
+		"no separator": encode("2024-01-01T00:00:00Z"),
+		"bad time":     encode("yesterday|" + uuid.NewString()),
+		"bad id":       encode("2024-01-01T00:00:00Z|nope"),
+	} {
+		if _, err := DecodeMessageCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestCursorFor(t *testing.T) {
+	msg := &Message{ID: uuid.New(), CreatedAt: time.Now()}
+	c := CursorFor(msg)
+	if c.ID != msg.ID || !c.CreatedAt.Equal(msg.CreatedAt) {
+		t.Errorf("CursorFor = %+v", c)
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConversationInvite is a shareable link that lets anyone holding Token join
+// a group conversation, or ask to join it when RequiresApproval is set.
+type ConversationInvite struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ConversationID   uuid.UUID  `json:"conversationId" gorm:"type:uuid;not null;index"`
+	Token            string     `json:"token" gorm:"type:varchar(64);not null;uniqueIndex"`
+	CreatedBy        uuid.UUID  `json:"createdBy" gorm:"type:uuid;not null"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	MaxUses          int        `json:"maxUses,omitempty" gorm:"not null;default:0"` // 0 = unlimited
+	Uses             int        `json:"uses" gorm:"not null;default:0"`
+	RequiresApproval bool       `json:"requiresApproval" gorm:"not null;default:false"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+func (ConversationInvite) TableName() string {
+	return "conversation_invites"
+}
+
+// Usable reports whether the invite can still be accepted at now.
+func (i *ConversationInvite) Usable(now time.Time) bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// Join request statuses
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+// JoinRequest is a user's request to join through an invite that requires
+// approval. A user has at most one pending request per conversation.
+type JoinRequest struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ConversationID uuid.UUID  `json:"conversationId" gorm:"type:uuid;not null;index;uniqueIndex:idx_join_requests_pending,priority:1,where:status = 'pending'"`
+	UserID         uuid.UUID  `json:"userId" gorm:"type:uuid;not null;uniqueIndex:idx_join_requests_pending,priority:2"`
+	InviteID       uuid.UUID  `json:"inviteId" gorm:"type:uuid;not null"`
+	Status         string     `json:"status" gorm:"type:varchar(20);not null;default:'pending'"` // pending, approved, rejected
+	DecidedBy      *uuid.UUID `json:"decidedBy,omitempty" gorm:"type:uuid"`
+	DecidedAt      *time.Time `json:"decidedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+func (JoinRequest) TableName() string {
+	return "join_requests"
+}
+
+// CreateInviteRequest configures a new invite. All fields are optional.
+type CreateInviteRequest struct {
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	MaxUses          int        `json:"maxUses,omitempty" binding:"min=0"`
+	RequiresApproval bool       `json:"requiresApproval,omitempty"`
+}
+
+// AcceptInviteResponse is the result of accepting an invite: the new
+// membership, or the pending join request when the invite requires approval.
+type AcceptInviteResponse struct {
+	ConversationID uuid.UUID           `json:"conversationId"`
+	Member         *ConversationMember `json:"member,omitempty"`
+	JoinRequest    *JoinRequest        `json:"joinRequest,omitempty"`
+}
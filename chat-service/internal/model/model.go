@@ -8,50 +8,225 @@ import (
 )
 
 type Message struct {
-	ID             uuid.UUID              `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	ConversationID uuid.UUID              `json:"conversationId" gorm:"type:uuid;not null;index"`
-	SenderID       uuid.UUID              `json:"senderId" gorm:"type:uuid;not null;index"`
-	Content        string                 `json:"content" gorm:"type:text;not null"`
-	Type           string                 `json:"type" gorm:"type:varchar(20);not null;default:'text'"` // text, image, file, video
-	Metadata       map[string]interface{} `json:"metadata,omitempty" gorm:"type:jsonb"`
-	CreatedAt      time.Time              `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt      time.Time              `json:"updatedAt" gorm:"autoUpdateTime"`
-	DeletedAt      gorm.DeletedAt         `json:"-" gorm:"index"`
+	ID                uuid.UUID              `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ConversationID    uuid.UUID              `json:"conversationId" gorm:"type:uuid;not null;index;uniqueIndex:idx_messages_conversation_seq,priority:1;uniqueIndex:idx_messages_client_msg,priority:1,where:client_msg_id IS NOT NULL"`
+	Seq               int64                  `json:"seq" gorm:"not null;default:0;uniqueIndex:idx_messages_conversation_seq,priority:2"` // gap-free per conversation
+	SenderID          uuid.UUID              `json:"senderId" gorm:"type:uuid;not null;index;uniqueIndex:idx_messages_client_msg,priority:2"`
+	ClientMsgID       *string                `json:"clientMsgId,omitempty" gorm:"type:varchar(64);uniqueIndex:idx_messages_client_msg,priority:3"` // sender-generated, for idempotent retries
+	Content           string                 `json:"content" gorm:"type:text;not null"`
+	Type              string                 `json:"type" gorm:"type:varchar(20);not null;default:'text'"` // text, image, file, video
+	Metadata          map[string]interface{} `json:"metadata,omitempty" gorm:"type:jsonb"`
+	Mentions          []string               `json:"mentions,omitempty" gorm:"type:jsonb;serializer:json"` // user IDs, "all" or "here"
+	ReplyToID         *uuid.UUID             `json:"replyToId,omitempty" gorm:"type:uuid"`                 // inline quote
+	ThreadRootID      *uuid.UUID             `json:"threadRootId,omitempty" gorm:"type:uuid;index"`        // set on thread replies
+	ThreadReplyCount  int64                  `json:"threadReplyCount,omitempty" gorm:"not null;default:0"` // set on thread roots
+	ThreadLastReplyAt *time.Time             `json:"threadLastReplyAt,omitempty"`
+	AttachmentIDs     []uuid.UUID            `json:"attachmentIds,omitempty" gorm:"type:jsonb;serializer:json;index:idx_messages_attachment_ids,type:gin"`
+	ForwardedFrom     *ForwardedFrom         `json:"forwardedFrom,omitempty" gorm:"type:jsonb;serializer:json"`
+	EditedAt          *time.Time             `json:"editedAt,omitempty"`
+	CreatedAt         time.Time              `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time              `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt         gorm.DeletedAt         `json:"-" gorm:"index"`
+	Deleted           bool                   `json:"deleted,omitempty" gorm:"-"` // tombstone, content is cleared
+	Reactions         []ReactionSummary      `json:"reactions,omitempty" gorm:"-"`
 }
 
 func (Message) TableName() string {
 	return "messages"
 }
 
+func (m *Message) AfterFind(tx *gorm.DB) error {
+	m.Deleted = m.DeletedAt.Valid
+	return nil
+}
+
+// MessageTypeSystem marks messages generated by the server, such as
+// membership changes. Content holds the action and Metadata its details.
+const MessageTypeSystem = "system"
+
+// System message actions
+const (
+	SystemMemberAdded     = "member_added"
+	SystemMemberRemoved   = "member_removed"
+	SystemMemberLeft      = "member_left"
+	SystemMemberJoined    = "member_joined" // through an invite link
+	SystemRoleChanged     = "member_role_changed"
+	SystemMessagePinned   = "message_pinned"
+	SystemMessageUnpinned = "message_unpinned"
+)
+
+// IsUserMessageType reports whether clients may send messages of this type.
+func IsUserMessageType(t string) bool {
+	switch t {
+	case "text", "image", "file", "video":
+		return true
+	}
+	return false
+}
+
+// ForwardedFrom records where a forwarded message originally came from.
+// ConversationID and MessageID are only kept when the original was posted in
+// a group, so forwarding never reveals a direct conversation.
+type ForwardedFrom struct {
+	SenderID       uuid.UUID  `json:"senderId"`
+	ConversationID *uuid.UUID `json:"conversationId,omitempty"`
+	MessageID      *uuid.UUID `json:"messageId,omitempty"`
+	SentAt         time.Time  `json:"sentAt"`
+}
+
+// Delete scopes
+const (
+	DeleteScopeMe       = "me"
+	DeleteScopeEveryone = "everyone"
+)
+
+// MessageReaction is one user's emoji reaction to a message.
+type MessageReaction struct {
+	MessageID uuid.UUID `json:"messageId" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `json:"userId" gorm:"type:uuid;primaryKey"`
+	Emoji     string    `json:"emoji" gorm:"type:varchar(64);primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+func (MessageReaction) TableName() string {
+	return "message_reactions"
+}
+
+// PinnedMessage is a message pinned to the top of its conversation.
+type PinnedMessage struct {
+	ConversationID uuid.UUID `json:"conversationId" gorm:"type:uuid;primaryKey"`
+	MessageID      uuid.UUID `json:"messageId" gorm:"type:uuid;primaryKey"`
+	PinnedBy       uuid.UUID `json:"pinnedBy" gorm:"type:uuid;not null"`
+	PinnedAt       time.Time `json:"pinnedAt" gorm:"autoCreateTime"`
+	Message        *Message  `json:"message,omitempty" gorm:"foreignKey:MessageID"`
+}
+
+func (PinnedMessage) TableName() string {
+	return "pinned_messages"
+}
+
+// ReactionSummary aggregates the reactions to a message for one emoji.
+// Reacted tells whether the requesting user is among them; it is omitted in
+// broadcasts, which are shared by every recipient.
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted,omitempty"`
+}
+
+// Mention targets besides user IDs
+const (
+	MentionAll  = "all"  // every member
+	MentionHere = "here" // members online when the message is sent
+)
+
+// MessageMention records that a message mentions a user, for their mentions
+// inbox. CreatedAt is the message's creation time.
+type MessageMention struct {
+	MessageID      uuid.UUID `json:"messageId" gorm:"type:uuid;primaryKey"`
+	UserID         uuid.UUID `json:"userId" gorm:"type:uuid;primaryKey;index:idx_message_mentions_user,priority:1"`
+	ConversationID uuid.UUID `json:"conversationId" gorm:"type:uuid;not null"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"index:idx_message_mentions_user,priority:2"`
+}
+
+func (MessageMention) TableName() string {
+	return "message_mentions"
+}
+
+// HiddenMessage hides a message from a single user's history ("delete for me").
+type HiddenMessage struct {
+	MessageID uuid.UUID `json:"messageId" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `json:"userId" gorm:"type:uuid;primaryKey;index"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+func (HiddenMessage) TableName() string {
+	return "hidden_messages"
+}
+
+// MessageEdit keeps the content a message had before an edit.
+type MessageEdit struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	MessageID uuid.UUID `json:"messageId" gorm:"type:uuid;not null;index"`
+	Content   string    `json:"content" gorm:"type:text;not null"`
+	EditedBy  uuid.UUID `json:"editedBy" gorm:"type:uuid;not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+func (MessageEdit) TableName() string {
+	return "message_edits"
+}
+
 type Conversation struct {
-	ID        uuid.UUID            `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Name      string               `json:"name" gorm:"type:varchar(255)"`
-	Type      string               `json:"type" gorm:"type:varchar(20);not null"` // direct, group
-	AvatarURL string               `json:"avatarUrl,omitempty" gorm:"type:varchar(500)"`
-	CreatedBy uuid.UUID            `json:"createdBy" gorm:"type:uuid;not null"`
-	CreatedAt time.Time            `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt time.Time            `json:"updatedAt" gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt       `json:"-" gorm:"index"`
-	Members   []ConversationMember `json:"members,omitempty" gorm:"foreignKey:ConversationID"`
+	ID          uuid.UUID            `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name        string               `json:"name" gorm:"type:varchar(255)"`
+	Type        string               `json:"type" gorm:"type:varchar(20);not null"` // direct, group
+	AvatarURL   string               `json:"avatarUrl,omitempty" gorm:"type:varchar(500)"`
+	Description string               `json:"description,omitempty" gorm:"type:varchar(1000)"`
+	DirectKey   *string              `json:"-" gorm:"type:varchar(73);uniqueIndex:idx_conversations_direct_key,where:deleted_at IS NULL"` // sorted member pair of a direct conversation
+	Permissions Policy               `json:"permissions,omitempty" gorm:"type:jsonb;serializer:json"`                                     // overrides of DefaultPolicy
+	LastSeq     int64                `json:"lastSeq" gorm:"not null;default:0"`                                                           // seq of the latest message
+	CreatedBy   uuid.UUID            `json:"createdBy" gorm:"type:uuid;not null"`
+	CreatedAt   time.Time            `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time            `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt       `json:"-" gorm:"index"`
+	Members     []ConversationMember `json:"members,omitempty" gorm:"foreignKey:ConversationID"`
 }
 
 func (Conversation) TableName() string {
 	return "conversations"
 }
 
+// DirectKey returns the canonical key of the direct conversation between two
+// users: both IDs in ascending order, so it is the same for either ordering.
+func DirectKey(a, b uuid.UUID) string {
+	x, y := a.String(), b.String()
+	if y < x {
+		x, y = y, x
+	}
+	return x + ":" + y
+}
+
 type ConversationMember struct {
-	ID             uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	ConversationID uuid.UUID      `json:"conversationId" gorm:"type:uuid;not null;index"`
-	UserID         uuid.UUID      `json:"userId" gorm:"type:uuid;not null;index"`
-	Role           string         `json:"role" gorm:"type:varchar(20);not null;default:'member'"` // admin, member
-	JoinedAt       time.Time      `json:"joinedAt" gorm:"autoCreateTime"`
-	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ConversationID    uuid.UUID      `json:"conversationId" gorm:"type:uuid;not null;index;uniqueIndex:idx_conversation_members_unique,priority:1,where:deleted_at IS NULL"`
+	UserID            uuid.UUID      `json:"userId" gorm:"type:uuid;not null;index;uniqueIndex:idx_conversation_members_unique,priority:2"`
+	Role              string         `json:"role" gorm:"type:varchar(20);not null;default:'member'"` // owner, admin, moderator, member, readonly
+	JoinedAt          time.Time      `json:"joinedAt" gorm:"autoCreateTime"`
+	LastReadMessageID *uuid.UUID     `json:"lastReadMessageId,omitempty" gorm:"type:uuid"`
+	LastReadAt        *time.Time     `json:"lastReadAt,omitempty"`
+	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 func (ConversationMember) TableName() string {
 	return "conversation_members"
 }
 
+// MessagePreview is the short form of a conversation's latest message.
+type MessagePreview struct {
+	ID        uuid.UUID `json:"id"`
+	SenderID  uuid.UUID `json:"senderId"`
+	Snippet   string    `json:"snippet"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ConversationSummary is a conversation as listed in a user's inbox.
+type ConversationSummary struct {
+	Conversation
+	LastMessage *MessagePreview       `json:"lastMessage,omitempty"`
+	UnreadCount int64                 `json:"unreadCount"`
+	Settings    *ConversationSettings `json:"settings"` // the caller's own
+}
+
+// ReadReceipt reports that a member has read up to (and including) a message.
+type ReadReceipt struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	UserID         uuid.UUID `json:"userId"`
+	MessageID      uuid.UUID `json:"messageId"`
+	ReadAt         time.Time `json:"readAt"`
+}
+
 // Request DTOs
 type CreateConversationRequest struct {
 	Name      string      `json:"name" binding:"required"`
@@ -61,30 +236,69 @@ type CreateConversationRequest struct {
 
 type SendMessageRequest struct {
 	ConversationID uuid.UUID              `json:"conversationId" binding:"required"`
+	ClientMsgID    string                 `json:"clientMsgId,omitempty" binding:"max=64"`
 	Content        string                 `json:"content" binding:"required"`
 	Type           string                 `json:"type" binding:"required,oneof=text image file video"`
 	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	ReplyToID      *uuid.UUID             `json:"replyToId,omitempty"`
+	ThreadRootID   *uuid.UUID             `json:"threadRootId,omitempty"`
+	AttachmentIDs  []uuid.UUID            `json:"attachmentIds,omitempty" binding:"max=10"`
 }
 
-type GetMessagesRequest struct {
-	ConversationID uuid.UUID `json:"conversationId" binding:"required"`
-	Limit          int       `json:"limit" binding:"min=1,max=100"`
-	Offset         int       `json:"offset" binding:"min=0"`
+// UpdateConversationRequest changes only the fields that are present.
+type UpdateConversationRequest struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,max=255"`
+	AvatarURL   *string `json:"avatarUrl,omitempty" binding:"omitempty,max=500"`
+	Description *string `json:"description,omitempty" binding:"omitempty,max=1000"`
 }
 
-// WebSocket Message Types
-type WSMessage struct {
-	Type    string      `json:"type"` // join, leave, message, typing, read
-	Payload interface{} `json:"payload"`
+// UpdatePermissionsRequest maps actions to the minimum role allowed to
+// perform them. Only the actions present are changed.
+type UpdatePermissionsRequest struct {
+	Permissions Policy `json:"permissions" binding:"required"`
 }
 
-type JoinRoomPayload struct {
-	ConversationID uuid.UUID `json:"conversationId"`
-	UserID         uuid.UUID `json:"userId"`
+type AddMemberRequest struct {
+	UserID uuid.UUID `json:"userId" binding:"required"`
+	Role   string    `json:"role,omitempty" binding:"omitempty,oneof=admin moderator member readonly"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin moderator member readonly"`
+}
+
+type ForwardMessageRequest struct {
+	ConversationIDs []uuid.UUID `json:"conversationIds" binding:"required,min=1,max=20"`
+}
+
+// ForwardResult lists the copies created by a forward and the targets it
+// could not be delivered to.
+type ForwardResult struct {
+	Messages []Message        `json:"messages"`
+	Failed   []ForwardFailure `json:"failed,omitempty"`
 }
 
-type TypingPayload struct {
+type ForwardFailure struct {
 	ConversationID uuid.UUID `json:"conversationId"`
-	UserID         uuid.UUID `json:"userId"`
-	IsTyping       bool      `json:"isTyping"`
+	Error          string    `json:"error"`
+}
+
+type EditMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+type MarkReadRequest struct {
+	MessageID uuid.UUID `json:"messageId" binding:"required"`
+}
+
+type GetMessagesRequest struct {
+	ConversationID uuid.UUID `json:"conversationId" binding:"required"`
+	Limit          int       `json:"limit" binding:"min=1,max=100"`
+	Before         string    `json:"before,omitempty"`
+	After          string    `json:"after,omitempty"`
+	Around         string    `json:"around,omitempty"`
 }
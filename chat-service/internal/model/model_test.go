@@ -0,0 +1,36 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDirectKey(t *testing.T) {
+	a := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	b := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+
+	want := a.String() + ":" + b.String()
+	if got := DirectKey(a, b); got != want {
+		t.Errorf("DirectKey(a, b) = %q, want %q", got, want)
+	}
+	if got := DirectKey(b, a); got != want {
+		t.Errorf("DirectKey(b, a) = %q, want %q", got, want)
+	}
+
+	for i := 0; i < 100; i++ {
+		x, y := uuid.New(), uuid.New()
+		key := DirectKey(x, y)
+		if key != DirectKey(y, x) {
+			t.Fatalf("DirectKey is not symmetric for %s, %s", x, y)
+		}
+		if len(key) > 73 {
+			t.Fatalf("DirectKey(%s, %s) is %d bytes, longer than the column", x, y, len(key))
+		}
+		first, second, _ := strings.Cut(key, ":")
+		if first > second {
+			t.Fatalf("DirectKey(%s, %s) = %q is not sorted", x, y, key)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package model
+
+// Member roles, from most to least privileged
+const (
+	RoleOwner     = "owner"
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+	RoleReadonly  = "readonly"
+)
+
+var roleRanks = map[string]int{
+	RoleOwner:     4,
+	RoleAdmin:     3,
+	RoleModerator: 2,
+	RoleMember:    1,
+	RoleReadonly:  0,
+}
+
+// IsValidRole reports whether role is one of the member roles.
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleRank orders roles by privilege; unknown roles rank below readonly.
+func RoleRank(role string) int {
+	if rank, ok := roleRanks[role]; ok {
+		return rank
+	}
+	return -1
+}
+
+// Permission is an action on a conversation that a policy grants to a
+// minimum role.
+type Permission string
+
+const (
+	PermPost               Permission = "post"                // send, edit own messages, type
+	PermReact              Permission = "react"               // add and remove reactions
+	PermAddMembers         Permission = "add_members"         // add members and approve join requests
+	PermRemoveMembers      Permission = "remove_members"      // remove lower-ranked members
+	PermChangeRoles        Permission = "change_roles"        // change roles of lower-ranked members
+	PermPin                Permission = "pin"                 // pin and unpin messages
+	PermEditInfo           Permission = "edit_info"           // rename, change avatar and description
+	PermDeleteMessages     Permission = "delete_messages"     // delete other members' messages for everyone
+	PermDeleteConversation Permission = "delete_conversation" // delete the conversation
+	PermManagePermissions  Permission = "manage_permissions"  // change this policy; always owner-only
+)
+
+// Policy maps each permission to the minimum role that holds it.
+type Policy map[Permission]string
+
+var groupPolicy = Policy{
+	PermPost:               RoleMember,
+	PermReact:              RoleMember,
+	PermAddMembers:         RoleAdmin,
+	PermRemoveMembers:      RoleAdmin,
+	PermChangeRoles:        RoleAdmin,
+	PermPin:                RoleModerator,
+	PermEditInfo:           RoleAdmin,
+	PermDeleteMessages:     RoleModerator,
+	PermDeleteConversation: RoleOwner,
+	PermManagePermissions:  RoleOwner,
+}
+
+// In a direct conversation both people can do everything that applies to it
+var directPolicy = Policy{
+	PermPost:               RoleMember,
+	PermReact:              RoleMember,
+	PermAddMembers:         RoleOwner,
+	PermRemoveMembers:      RoleOwner,
+	PermChangeRoles:        RoleOwner,
+	PermPin:                RoleMember,
+	PermEditInfo:           RoleMember,
+	PermDeleteMessages:     RoleOwner,
+	PermDeleteConversation: RoleMember,
+	PermManagePermissions:  RoleOwner,
+}
+
+// DefaultPolicy returns the permissions a conversation of the given type has
+// before any overrides.
+func DefaultPolicy(conversationType string) Policy {
+	if conversationType == "direct" {
+		return directPolicy
+	}
+	return groupPolicy
+}
+
+// IsConfigurable reports whether p exists and may be overridden per conversation.
+func (p Permission) IsConfigurable() bool {
+	_, ok := groupPolicy[p]
+	return ok && p != PermManagePermissions
+}
+
+// EffectivePolicy overlays a conversation's overrides on the defaults for its type.
+func (c *Conversation) EffectivePolicy() Policy {
+	policy := make(Policy, len(groupPolicy))
+	for p, role := range DefaultPolicy(c.Type) {
+		policy[p] = role
+	}
+	for p, role := range c.Permissions {
+		if p.IsConfigurable() && IsValidRole(role) {
+			policy[p] = role
+		}
+	}
+	return policy
+}
@@ -0,0 +1,240 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProtocolVersion is the WebSocket protocol version spoken by the server.
+// Frames without a version are treated as the current version.
+const ProtocolVersion = 1
+
+// Client -> server frame types
+const (
+	WSJoinConversation  = "join_conversation"
+	WSLeaveConversation = "leave_conversation"
+	WSSendMessage       = "send_message"
+	WSEditMessage       = "edit_message"
+	WSDeleteMessage     = "delete_message"
+	WSMarkRead          = "mark_read"
+	WSAddReaction       = "add_reaction"
+	WSRemoveReaction    = "remove_reaction"
+	WSResume            = "resume"
+	WSTyping            = "typing"
+)
+
+// Server -> client frame types
+const (
+	WSNewMessage          = "new_message"
+	WSMessageUpdated      = "message_updated"
+	WSMessageDeleted      = "message_deleted"
+	WSMessageAck          = "message_ack"
+	WSMessageNack         = "message_nack"
+	WSReadReceipt         = "read_receipt"
+	WSReactionUpdated     = "reaction_updated"
+	WSThreadReply         = "thread_reply"
+	WSThreadUpdated       = "thread_updated"
+	WSMentioned           = "mentioned"
+	WSMemberAdded         = "member_added"
+	WSMemberRemoved       = "member_removed"
+	WSMemberUpdated       = "member_updated"
+	WSConversationUpdated = "conversation_updated"
+	WSConversationDeleted = "conversation_deleted"
+	WSSettingsUpdated     = "settings_updated"
+	WSMessagePinned       = "message_pinned"
+	WSMessageUnpinned     = "message_unpinned"
+	WSResumed             = "resumed"
+	WSResyncRequired      = "resync_required"
+	WSUserTyping          = "user_typing"
+	WSError               = "error"
+)
+
+// Error codes carried by error and message_nack frames
+const (
+	ErrCodeInvalidPayload     = "invalid_payload"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeNotMember          = "not_member"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeEditWindowExpired  = "edit_window_expired"
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeLimitExceeded      = "limit_exceeded"
+	ErrCodeInternal           = "internal"
+)
+
+// WSMessage is a frame sent by the client. ID is an optional client-chosen
+// request ID that the server echoes in direct replies (acks, errors).
+type WSMessage struct {
+	Version int             `json:"v,omitempty"`
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// WSEvent is a frame sent by the server. ID is set only on replies to a
+// client frame that carried one.
+type WSEvent struct {
+	Version int         `json:"v"`
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload"`
+}
+
+func NewWSEvent(eventType string, payload interface{}) *WSEvent {
+	return &WSEvent{Version: ProtocolVersion, Type: eventType, Payload: payload}
+}
+
+// JoinRoomPayload is used by join_conversation and leave_conversation. UserID
+// is ignored: the socket's authenticated user is always used.
+type JoinRoomPayload struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	UserID         uuid.UUID `json:"userId,omitempty"`
+}
+
+// TypingPayload is used by typing (client) and user_typing (server). UserID is
+// filled in by the server.
+type TypingPayload struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	UserID         uuid.UUID `json:"userId"`
+	IsTyping       bool      `json:"isTyping"`
+}
+
+type SendMessagePayload struct {
+	ConversationID uuid.UUID              `json:"conversationId"`
+	ClientMsgID    string                 `json:"clientMsgId,omitempty"`
+	Content        string                 `json:"content"`
+	Type           string                 `json:"type,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	ReplyToID      *uuid.UUID             `json:"replyToId,omitempty"`
+	ThreadRootID   *uuid.UUID             `json:"threadRootId,omitempty"`
+	AttachmentIDs  []uuid.UUID            `json:"attachmentIds,omitempty"`
+}
+
+type EditMessagePayload struct {
+	MessageID uuid.UUID `json:"messageId"`
+	Content   string    `json:"content"`
+}
+
+type DeleteMessagePayload struct {
+	MessageID uuid.UUID `json:"messageId"`
+	Scope     string    `json:"scope,omitempty"` // me (default), everyone
+}
+
+type MarkReadPayload struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	MessageID      uuid.UUID `json:"messageId"`
+}
+
+type ReactionPayload struct {
+	MessageID uuid.UUID `json:"messageId"`
+	Emoji     string    `json:"emoji"`
+}
+
+// ReactionUpdatedPayload reports one user adding or removing a reaction,
+// along with the message's new totals.
+type ReactionUpdatedPayload struct {
+	MessageID      uuid.UUID         `json:"messageId"`
+	ConversationID uuid.UUID         `json:"conversationId"`
+	UserID         uuid.UUID         `json:"userId"`
+	Emoji          string            `json:"emoji"`
+	Added          bool              `json:"added"`
+	Reactions      []ReactionSummary `json:"reactions"`
+}
+
+// ThreadUpdatedPayload is broadcast to the room when a thread gets a reply, so
+// clients can refresh the root's reply count without receiving the reply.
+type ThreadUpdatedPayload struct {
+	ConversationID uuid.UUID  `json:"conversationId"`
+	RootID         uuid.UUID  `json:"rootId"`
+	ReplyCount     int64      `json:"replyCount"`
+	LastReplyAt    *time.Time `json:"lastReplyAt,omitempty"`
+}
+
+// MemberPayload is used by member_added and member_updated.
+type MemberPayload struct {
+	ConversationID uuid.UUID           `json:"conversationId"`
+	Member         *ConversationMember `json:"member"`
+	ActorID        uuid.UUID           `json:"actorId"`
+}
+
+// MemberRemovedPayload is sent when a member leaves (ActorID == UserID) or is
+// removed by an admin.
+type MemberRemovedPayload struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	UserID         uuid.UUID `json:"userId"`
+	ActorID        uuid.UUID `json:"actorId"`
+}
+
+// ConversationUpdatedPayload carries the conversation's new details, without
+// its member list.
+type ConversationUpdatedPayload struct {
+	Conversation *Conversation `json:"conversation"`
+	ActorID      uuid.UUID     `json:"actorId"`
+}
+
+type ConversationDeletedPayload struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	ActorID        uuid.UUID `json:"actorId"`
+}
+
+// MessageUnpinnedPayload is sent when ActorID unpins a message. Pins are sent
+// as the PinnedMessage itself.
+type MessageUnpinnedPayload struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	MessageID      uuid.UUID `json:"messageId"`
+	ActorID        uuid.UUID `json:"actorId"`
+}
+
+type ResumeConversation struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	LastSeq        int64     `json:"lastSeq"`
+}
+
+// ResumePayload is sent after reconnecting. Since is the server timestamp of
+// the last event the client saw and is optional.
+type ResumePayload struct {
+	Since         *time.Time           `json:"since,omitempty"`
+	Conversations []ResumeConversation `json:"conversations"`
+}
+
+type MessageDeletedPayload struct {
+	ID             uuid.UUID  `json:"id"`
+	ConversationID uuid.UUID  `json:"conversationId"`
+	Seq            int64      `json:"seq"`
+	DeletedBy      *uuid.UUID `json:"deletedBy,omitempty"`
+}
+
+type MessageAckPayload struct {
+	ClientMsgID    *string   `json:"clientMsgId,omitempty"`
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversationId"`
+	Seq            int64     `json:"seq"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Duplicate      bool      `json:"duplicate"`
+}
+
+type MessageNackPayload struct {
+	ClientMsgID string `json:"clientMsgId,omitempty"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+}
+
+type ResumedPayload struct {
+	ConversationID uuid.UUID `json:"conversationId"`
+	Truncated      bool      `json:"truncated"`
+}
+
+// ResyncRequiredPayload lists conversations a resume could not replay. The
+// client reloads them through the REST API instead.
+type ResyncRequiredPayload struct {
+	ConversationIDs []uuid.UUID `json:"conversationIds"`
+}
+
+type ErrorPayload struct {
+	Code           string     `json:"code"`
+	Message        string     `json:"message"`
+	ConversationID *uuid.UUID `json:"conversationId,omitempty"`
+}
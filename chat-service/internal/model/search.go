@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchQuery is a full-text search over the messages a user can see. Query
+// uses web search syntax ("quoted phrases", OR, -excluded). Results are
+// ordered newest first; Before continues from a previous page.
+type SearchQuery struct {
+	UserID         uuid.UUID
+	Query          string
+	ConversationID *uuid.UUID
+	SenderID       *uuid.UUID
+	From           *time.Time
+	To             *time.Time
+	Type           string
+	Before         *MessageCursor
+	Limit          int
+}
+
+// SearchResult is a matching message with an HTML-escaped snippet of its
+// content in which the matched terms are wrapped in <mark></mark>.
+type SearchResult struct {
+	Message   Message `json:"message"`
+	Highlight string  `json:"highlight"`
+}
+
+// SearchPage is a page of search results, newest first. NextCursor loads the
+// next page (pass it as `before`).
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
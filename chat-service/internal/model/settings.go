@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification levels
+const (
+	NotifyAll      = "all"      // mentions and thread replies
+	NotifyMentions = "mentions" // mentions only
+	NotifyNone     = "none"
+)
+
+// ConversationSettings are one member's private preferences for a
+// conversation. Members without a stored row have the zero settings with
+// NotificationLevel "all".
+type ConversationSettings struct {
+	ConversationID    uuid.UUID  `json:"conversationId" gorm:"type:uuid;primaryKey"`
+	UserID            uuid.UUID  `json:"userId" gorm:"type:uuid;primaryKey;index"`
+	Muted             bool       `json:"muted" gorm:"not null;default:false"`
+	MutedUntil        *time.Time `json:"mutedUntil,omitempty"` // nil while muted = until unmuted
+	PinnedAt          *time.Time `json:"pinnedAt,omitempty"`
+	ArchivedAt        *time.Time `json:"archivedAt,omitempty"`
+	NotificationLevel string     `json:"notificationLevel" gorm:"type:varchar(20);not null;default:'all'"` // all, mentions, none
+	Nickname          string     `json:"nickname,omitempty" gorm:"type:varchar(100)"`
+	UpdatedAt         time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+func (ConversationSettings) TableName() string {
+	return "conversation_settings"
+}
+
+// DefaultSettings returns the settings of a member who never changed them.
+func DefaultSettings(conversationID, userID uuid.UUID) *ConversationSettings {
+	return &ConversationSettings{
+		ConversationID:    conversationID,
+		UserID:            userID,
+		NotificationLevel: NotifyAll,
+	}
+}
+
+// IsMuted reports whether the conversation is muted at now.
+func (s *ConversationSettings) IsMuted(now time.Time) bool {
+	return s.Muted && (s.MutedUntil == nil || now.Before(*s.MutedUntil))
+}
+
+// Notifies reports whether the member wants direct notifications at now: for
+// mentions if mention is true, otherwise for activity such as thread replies.
+func (s *ConversationSettings) Notifies(now time.Time, mention bool) bool {
+	if s.IsMuted(now) {
+		return false
+	}
+	switch s.NotificationLevel {
+	case NotifyNone:
+		return false
+	case NotifyMentions:
+		return mention
+	default:
+		return true
+	}
+}
+
+// UpdateSettingsRequest changes only the fields that are present. Setting
+// mutedUntil implies muted; muting without it lasts until unmuted.
+type UpdateSettingsRequest struct {
+	Muted             *bool      `json:"muted,omitempty"`
+	MutedUntil        *time.Time `json:"mutedUntil,omitempty"`
+	Pinned            *bool      `json:"pinned,omitempty"`
+	Archived          *bool      `json:"archived,omitempty"`
+	NotificationLevel *string    `json:"notificationLevel,omitempty" binding:"omitempty,oneof=all mentions none"`
+	Nickname          *string    `json:"nickname,omitempty" binding:"omitempty,max=100"`
+}
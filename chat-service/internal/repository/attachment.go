@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrAttachmentUnavailable is returned by Create when a message references an
+// attachment that doesn't exist, was uploaded by someone else or to another
+// conversation, or is already attached to a message.
+var ErrAttachmentUnavailable = errors.New("attachment unavailable")
+
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *model.Attachment) error
+	FindByID(ctx context.Context, id uuid.UUID) (*model.Attachment, error)
+	CanAccess(ctx context.Context, attachment *model.Attachment, userID uuid.UUID) (bool, error)
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) Create(ctx context.Context, attachment *model.Attachment) error {
+	return r.db.WithContext(ctx).Create(attachment).Error
+}
+
+func (r *attachmentRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Attachment, error) {
+	var attachment model.Attachment
+	if err := r.db.WithContext(ctx).First(&attachment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// CanAccess reports whether userID may download the attachment. Until it is
+// sent only the uploader can; afterwards every member of a conversation with
+// a live message referencing it can, which covers forwarded copies.
+func (r *attachmentRepository) CanAccess(ctx context.Context, attachment *model.Attachment, userID uuid.UUID) (bool, error) {
+	if attachment.MessageID == nil {
+		return attachment.UploaderID == userID, nil
+	}
+
+	ref, err := json.Marshal([]uuid.UUID{attachment.ID})
+	if err != nil {
+		return false, err
+	}
+
+	var found bool
+	err = r.db.WithContext(ctx).Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM messages m
+			JOIN conversation_members cm ON cm.conversation_id = m.conversation_id
+				AND cm.user_id = ? AND cm.deleted_at IS NULL
+			WHERE m.attachment_ids @> ?::jsonb AND m.deleted_at IS NULL
+		)`, userID, string(ref)).Scan(&found).Error
+	return found, err
+}
+
+// linkAttachments attaches msg's attachments to it inside the transaction
+// that creates it. Each must be an unsent upload of the sender's to the same
+// conversation.
+func linkAttachments(tx *gorm.DB, msg *model.Message) error {
+	result := tx.Model(&model.Attachment{}).
+		Where("id IN ? AND message_id IS NULL AND uploader_id = ? AND conversation_id = ?",
+			msg.AttachmentIDs, msg.SenderID, msg.ConversationID).
+		Update("message_id", msg.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(msg.AttachmentIDs)) {
+		return ErrAttachmentUnavailable
+	}
+	return nil
+}
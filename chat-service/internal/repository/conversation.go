@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/chatmenow/chat-service/internal/model"
 	"github.com/google/uuid"
@@ -11,11 +12,22 @@ import (
 type ConversationRepository interface {
 	Create(ctx context.Context, conv *model.Conversation, memberIDs []uuid.UUID) error
 	FindByID(ctx context.Context, id uuid.UUID) (*model.Conversation, error)
-	FindByUser(ctx context.Context, userID uuid.UUID) ([]model.Conversation, error)
+	FindByDirectKey(ctx context.Context, key string) (*model.Conversation, error)
+	FindByUser(ctx context.Context, userID uuid.UUID, archived bool) ([]model.ConversationSummary, error)
+	FindSettings(ctx context.Context, conversationID, userID uuid.UUID) (*model.ConversationSettings, error)
+	FindMembersSettings(ctx context.Context, conversationID uuid.UUID, userIDs []uuid.UUID) ([]model.ConversationSettings, error)
+	SaveSettings(ctx context.Context, settings *model.ConversationSettings) error
 	GetMembers(ctx context.Context, conversationID uuid.UUID) ([]model.ConversationMember, error)
 	AddMember(ctx context.Context, member *model.ConversationMember) error
 	RemoveMember(ctx context.Context, conversationID, userID uuid.UUID) error
+	UpdateMemberRole(ctx context.Context, conversationID, userID uuid.UUID, role string) (*model.ConversationMember, error)
+	TransferOwnership(ctx context.Context, conversationID, fromUserID, toUserID uuid.UUID) ([]*model.ConversationMember, error)
 	Update(ctx context.Context, conv *model.Conversation) error
+	UpdateDetails(ctx context.Context, conversationID uuid.UUID, updates map[string]interface{}) error
+	UpdatePermissions(ctx context.Context, conversationID uuid.UUID, policy model.Policy) error
+	Delete(ctx context.Context, conversationID uuid.UUID) error
+	MarkRead(ctx context.Context, conversationID, userID, messageID uuid.UUID, readAt time.Time) (bool, error)
+	FindReadSince(ctx context.Context, conversationID uuid.UUID, since time.Time) ([]model.ConversationMember, error)
 }
 
 type conversationRepository struct {
@@ -34,16 +46,16 @@ func (r *conversationRepository) Create(ctx context.Context, conv *model.Convers
 		}
 
 		// Add members
-		for i, memberID := range memberIDs {
+		for _, memberID := range memberIDs {
 			member := &model.ConversationMember{
 				ConversationID: conv.ID,
 				UserID:         memberID,
 				Role:           "member",
 			}
 
-			// First member (creator) is admin
-			if i == 0 || memberID == conv.CreatedBy {
-				member.Role = "admin"
+			// The creator owns a group; both people in a direct chat are equals
+			if conv.Type != "direct" && memberID == conv.CreatedBy {
+				member.Role = model.RoleOwner
 			}
 
 			if err := tx.Create(member).Error; err != nil {
@@ -66,21 +78,133 @@ func (r *conversationRepository) FindByID(ctx context.Context, id uuid.UUID) (*m
 	return &conversation, nil
 }
 
-func (r *conversationRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]model.Conversation, error) {
-	var conversations []model.Conversation
-
+// FindByDirectKey returns the live direct conversation with the given key.
+func (r *conversationRepository) FindByDirectKey(ctx context.Context, key string) (*model.Conversation, error) {
+	var conversation model.Conversation
 	err := r.db.WithContext(ctx).
-		Joins("JOIN conversation_members ON conversation_members.conversation_id = conversations.id").
-		Where("conversation_members.user_id = ? AND conversation_members.deleted_at IS NULL", userID).
 		Preload("Members").
-		Order("conversations.updated_at DESC").
+		First(&conversation, "direct_key = ?", key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+const snippetLength = 100
+
+// inboxRow is one row of the inbox query: a conversation the user belongs to,
+// its latest visible message and the user's unread count.
+type inboxRow struct {
+	ConversationID uuid.UUID
+	LastMessageID  *uuid.UUID
+	LastSenderID   *uuid.UUID
+	LastSnippet    *string
+	LastType       *string
+	LastMessageAt  *time.Time
+	UnreadCount    int64
+}
+
+// FindByUser lists the user's archived or unarchived conversations, pinned
+// ones first and then by last activity, each with a preview of its latest
+// message, the number of messages from others newer than the user's read
+// pointer and the user's settings. Thread replies don't count.
+func (r *conversationRepository) FindByUser(ctx context.Context, userID uuid.UUID, archived bool) ([]model.ConversationSummary, error) {
+	var rows []inboxRow
+
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT
+			c.id AS conversation_id,
+			lm.id AS last_message_id,
+			lm.sender_id AS last_sender_id,
+			LEFT(lm.content, ?) AS last_snippet,
+			lm.type AS last_type,
+			lm.created_at AS last_message_at,
+			(
+				SELECT COUNT(*) FROM messages um
+				WHERE um.conversation_id = c.id
+					AND um.deleted_at IS NULL
+					AND um.thread_root_id IS NULL
+					AND um.sender_id <> cm.user_id
+					AND (cm.last_read_message_id IS NULL OR um.created_at >
+						(SELECT lr.created_at FROM messages lr WHERE lr.id = cm.last_read_message_id))
+			) AS unread_count
+		FROM conversation_members cm
+		JOIN conversations c ON c.id = cm.conversation_id AND c.deleted_at IS NULL
+		LEFT JOIN conversation_settings cs ON cs.conversation_id = c.id AND cs.user_id = cm.user_id
+		LEFT JOIN LATERAL (
+			SELECT m.id, m.sender_id, m.content, m.type, m.created_at
+			FROM messages m
+			WHERE m.conversation_id = c.id
+				AND m.deleted_at IS NULL
+				AND m.thread_root_id IS NULL
+				AND NOT EXISTS (SELECT 1 FROM hidden_messages h WHERE h.message_id = m.id AND h.user_id = cm.user_id)
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		) lm ON true
+		WHERE cm.user_id = ? AND cm.deleted_at IS NULL
+			AND (cs.archived_at IS NOT NULL) = ?
+		ORDER BY cs.pinned_at IS NULL, cs.pinned_at DESC, COALESCE(lm.created_at, c.created_at) DESC`, snippetLength, userID, archived).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return []model.ConversationSummary{}, nil
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ConversationID
+	}
+
+	var conversations []model.Conversation
+	err = r.db.WithContext(ctx).
+		Preload("Members").
+		Where("id IN ?", ids).
 		Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
 
+	byID := make(map[uuid.UUID]model.Conversation, len(conversations))
+	for _, conv := range conversations {
+		byID[conv.ID] = conv
+	}
+
+	settings, err := r.findSettings(ctx, userID, ids)
 	if err != nil {
 		return nil, err
 	}
 
-	return conversations, nil
+	summaries := make([]model.ConversationSummary, 0, len(rows))
+	for _, row := range rows {
+		conv, ok := byID[row.ConversationID]
+		if !ok {
+			continue
+		}
+
+		summary := model.ConversationSummary{
+			Conversation: conv,
+			UnreadCount:  row.UnreadCount,
+			Settings:     settings[conv.ID],
+		}
+		if summary.Settings == nil {
+			summary.Settings = model.DefaultSettings(conv.ID, userID)
+		}
+		if row.LastMessageID != nil {
+			summary.LastMessage = &model.MessagePreview{
+				ID:        *row.LastMessageID,
+				SenderID:  *row.LastSenderID,
+				Snippet:   *row.LastSnippet,
+				Type:      *row.LastType,
+				CreatedAt: *row.LastMessageAt,
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
 }
 
 func (r *conversationRepository) GetMembers(ctx context.Context, conversationID uuid.UUID) ([]model.ConversationMember, error) {
@@ -94,6 +218,25 @@ func (r *conversationRepository) GetMembers(ctx context.Context, conversationID
 	return members, nil
 }
 
+// DedupeMembers soft-deletes all but the earliest live membership of each user
+// in each conversation. Databases built by AutoMigrate before members had a
+// unique (conversation_id, user_id) index may hold duplicates, which would
+// make AutoMigrate fail to build it, so this runs first. It does nothing once
+// the index exists.
+func DedupeMembers(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&model.ConversationMember{}) || migrator.HasIndex(&model.ConversationMember{}, "idx_conversation_members_unique") {
+		return nil
+	}
+
+	return db.Exec(`UPDATE conversation_members cm SET deleted_at = NOW()
+		WHERE cm.deleted_at IS NULL AND EXISTS (
+			SELECT 1 FROM conversation_members o
+			WHERE o.conversation_id = cm.conversation_id AND o.user_id = cm.user_id
+				AND o.deleted_at IS NULL AND (o.joined_at, o.id) < (cm.joined_at, cm.id)
+		)`).Error
+}
+
 func (r *conversationRepository) AddMember(ctx context.Context, member *model.ConversationMember) error {
 	return r.db.WithContext(ctx).Create(member).Error
 }
@@ -104,6 +247,126 @@ func (r *conversationRepository) RemoveMember(ctx context.Context, conversationI
 		Delete(&model.ConversationMember{}).Error
 }
 
+// UpdateMemberRole changes a member's role and returns the updated member.
+func (r *conversationRepository) UpdateMemberRole(ctx context.Context, conversationID, userID uuid.UUID, role string) (*model.ConversationMember, error) {
+	var member *model.ConversationMember
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		member, err = setRole(tx, conversationID, userID, role)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// TransferOwnership makes toUserID the owner and demotes fromUserID to admin
+// in one transaction, returning both updated members, new owner first.
+func (r *conversationRepository) TransferOwnership(ctx context.Context, conversationID, fromUserID, toUserID uuid.UUID) ([]*model.ConversationMember, error) {
+	var owner, previous *model.ConversationMember
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if previous, err = setRole(tx, conversationID, fromUserID, model.RoleAdmin); err != nil {
+			return err
+		}
+		owner, err = setRole(tx, conversationID, toUserID, model.RoleOwner)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*model.ConversationMember{owner, previous}, nil
+}
+
+func setRole(tx *gorm.DB, conversationID, userID uuid.UUID, role string) (*model.ConversationMember, error) {
+	var member model.ConversationMember
+	if err := tx.Where("conversation_id = ? AND user_id = ?", conversationID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	member.Role = role
+	if err := tx.Model(&member).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
 func (r *conversationRepository) Update(ctx context.Context, conv *model.Conversation) error {
 	return r.db.WithContext(ctx).Save(conv).Error
 }
+
+// UpdateDetails updates the given columns of a conversation without touching
+// its members.
+func (r *conversationRepository) UpdateDetails(ctx context.Context, conversationID uuid.UUID, updates map[string]interface{}) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Conversation{}).
+		Where("id = ?", conversationID).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdatePermissions replaces the conversation's permission overrides.
+func (r *conversationRepository) UpdatePermissions(ctx context.Context, conversationID uuid.UUID, policy model.Policy) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Conversation{ID: conversationID}).
+		Select("permissions").
+		Updates(&model.Conversation{Permissions: policy}).Error
+}
+
+// Delete soft-deletes a conversation together with its members and messages,
+// in one transaction.
+func (r *conversationRepository) Delete(ctx context.Context, conversationID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", conversationID).Delete(&model.Conversation{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("conversation_id = ?", conversationID).Delete(&model.ConversationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("conversation_id = ?", conversationID).Delete(&model.Message{}).Error
+	})
+}
+
+// MarkRead moves the member's read pointer to messageID. The pointer only moves
+// forward: it reports false if the member had already read a later message or
+// the message does not belong to the conversation.
+func (r *conversationRepository) MarkRead(ctx context.Context, conversationID, userID, messageID uuid.UUID, readAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.ConversationMember{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Where("EXISTS (SELECT 1 FROM messages m WHERE m.id = ? AND m.conversation_id = ?)", messageID, conversationID).
+		Where(`(last_read_message_id IS NULL OR
+			(SELECT created_at FROM messages WHERE id = conversation_members.last_read_message_id) <=
+			(SELECT created_at FROM messages WHERE id = ?))`, messageID).
+		Updates(map[string]interface{}{
+			"last_read_message_id": messageID,
+			"last_read_at":         readAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindReadSince returns members whose read pointer moved after since.
+func (r *conversationRepository) FindReadSince(ctx context.Context, conversationID uuid.UUID, since time.Time) ([]model.ConversationMember, error) {
+	var members []model.ConversationMember
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND last_read_at > ?", conversationID, since).
+		Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
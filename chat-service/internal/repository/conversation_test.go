@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestMarkReadScopesOrToMember(t *testing.T) {
+	db, recorder := dryRunDB(t)
+	repo := NewConversationRepository(db)
+
+	repo.MarkRead(context.Background(), uuid.New(), uuid.New(), uuid.New(), time.Now())
+
+	sql := recorder.last(t)
+	if !strings.Contains(sql, "AND (last_read_message_id IS NULL OR") {
+		t.Fatalf("the OR of the forward-only check is not parenthesized:\n%s", sql)
+	}
+}
+
+func TestMarkReadLeavesOtherMembersAlone(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	convRepo := NewConversationRepository(db)
+	msgRepo := NewMessageRepository(db)
+
+	reader, other := uuid.New(), uuid.New()
+	read := &model.Conversation{Type: "group", Name: "read", CreatedBy: reader}
+	elsewhere := &model.Conversation{Type: "group", Name: "elsewhere", CreatedBy: other}
+	for _, conv := range []*model.Conversation{read, elsewhere} {
+		if err := db.Create(conv).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	members := []model.ConversationMember{
+		{ConversationID: read.ID, UserID: reader},
+		{ConversationID: read.ID, UserID: other},
+		{ConversationID: elsewhere.ID, UserID: other},
+	}
+	if err := db.Create(&members).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &model.Message{ConversationID: read.ID, SenderID: other, Content: "hi", Type: "text"}
+	if err := msgRepo.Create(ctx, msg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := convRepo.MarkRead(ctx, read.ID, reader, msg.ID, time.Now())
+	if err != nil || !moved {
+		t.Fatalf("MarkRead = %v, %v; want true", moved, err)
+	}
+
+	var after []model.ConversationMember
+	if err := db.Where("conversation_id IN ?", []uuid.UUID{read.ID, elsewhere.ID}).Find(&after).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range after {
+		isReader := m.ConversationID == read.ID && m.UserID == reader
+		if isReader && (m.LastReadMessageID == nil || *m.LastReadMessageID != msg.ID) {
+			t.Errorf("reader's pointer = %v, want %s", m.LastReadMessageID, msg.ID)
+		}
+		if !isReader && m.LastReadMessageID != nil {
+			t.Errorf("member %s of %s was moved to %s", m.UserID, m.ConversationID, *m.LastReadMessageID)
+		}
+	}
+}
+
+func TestAddMemberIsUnique(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewConversationRepository(db)
+
+	user := uuid.New()
+	conv := &model.Conversation{Type: "group", Name: "unique members", CreatedBy: user}
+	if err := db.Create(conv).Error; err != nil {
+		t.Fatal(err)
+	}
+	add := func() error {
+		return repo.AddMember(ctx, &model.ConversationMember{ConversationID: conv.ID, UserID: user, Role: model.RoleMember})
+	}
+
+	if err := add(); err != nil {
+		t.Fatal(err)
+	}
+	if err := add(); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("second AddMember = %v, want ErrDuplicatedKey", err)
+	}
+
+	// A member who left can be added again
+	if err := repo.RemoveMember(ctx, conv.ID, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := add(); err != nil {
+		t.Errorf("AddMember after leaving = %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type InviteRepository interface {
+	Create(ctx context.Context, invite *model.ConversationInvite) error
+	FindByToken(ctx context.Context, token string) (*model.ConversationInvite, error)
+	FindByConversation(ctx context.Context, conversationID uuid.UUID) ([]model.ConversationInvite, error)
+	Revoke(ctx context.Context, conversationID, inviteID uuid.UUID) error
+	Use(ctx context.Context, inviteID uuid.UUID, now time.Time) (bool, error)
+	Join(ctx context.Context, inviteID uuid.UUID, member *model.ConversationMember, now time.Time) (bool, error)
+	RequestJoin(ctx context.Context, inviteID uuid.UUID, req *model.JoinRequest, now time.Time) (bool, error)
+	FindJoinRequest(ctx context.Context, conversationID, requestID uuid.UUID) (*model.JoinRequest, error)
+	FindPendingJoinRequest(ctx context.Context, conversationID, userID uuid.UUID) (*model.JoinRequest, error)
+	FindPendingJoinRequests(ctx context.Context, conversationID uuid.UUID) ([]model.JoinRequest, error)
+	DecideJoinRequest(ctx context.Context, req *model.JoinRequest, status string, decidedBy uuid.UUID) (bool, error)
+}
+
+type inviteRepository struct {
+	db *gorm.DB
+}
+
+func NewInviteRepository(db *gorm.DB) InviteRepository {
+	return &inviteRepository{db: db}
+}
+
+func (r *inviteRepository) Create(ctx context.Context, invite *model.ConversationInvite) error {
+	return r.db.WithContext(ctx).Create(invite).Error
+}
+
+func (r *inviteRepository) FindByToken(ctx context.Context, token string) (*model.ConversationInvite, error) {
+	var invite model.ConversationInvite
+	if err := r.db.WithContext(ctx).Where("token = ?", token).First(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// FindByConversation returns the conversation's invites that have not been
+// revoked, newest first.
+func (r *inviteRepository) FindByConversation(ctx context.Context, conversationID uuid.UUID) ([]model.ConversationInvite, error) {
+	var invites []model.ConversationInvite
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND revoked_at IS NULL", conversationID).
+		Order("created_at DESC").
+		Find(&invites).Error
+	return invites, err
+}
+
+// Revoke marks an invite revoked. It returns gorm.ErrRecordNotFound if the
+// conversation has no such live invite.
+func (r *inviteRepository) Revoke(ctx context.Context, conversationID, inviteID uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.ConversationInvite{}).
+		Where("id = ? AND conversation_id = ? AND revoked_at IS NULL", inviteID, conversationID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Use counts one use of the invite. It returns false, without counting, if the
+// invite was revoked, has expired or has no uses left, so concurrent accepts
+// can't exceed MaxUses.
+func (r *inviteRepository) Use(ctx context.Context, inviteID uuid.UUID, now time.Time) (bool, error) {
+	return useInvite(r.db.WithContext(ctx), inviteID, now)
+}
+
+// Join counts one use of the invite and adds member in a single transaction,
+// so an insert that fails (e.g. gorm.ErrDuplicatedKey for an existing member)
+// doesn't use up the invite. It returns false, adding nobody, if the invite
+// can't be used.
+func (r *inviteRepository) Join(ctx context.Context, inviteID uuid.UUID, member *model.ConversationMember, now time.Time) (bool, error) {
+	return r.redeem(ctx, inviteID, now, member)
+}
+
+// RequestJoin counts one use of the invite and stores a pending request in a
+// single transaction. If the user already has one pending for the
+// conversation, req is filled with it and no use is counted. It returns false,
+// storing nothing, if the invite can't be used.
+func (r *inviteRepository) RequestJoin(ctx context.Context, inviteID uuid.UUID, req *model.JoinRequest, now time.Time) (bool, error) {
+	ok, err := r.redeem(ctx, inviteID, now, req)
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ok, err
+	}
+	existing, err := r.FindPendingJoinRequest(ctx, req.ConversationID, req.UserID)
+	if err != nil {
+		return false, err
+	}
+	*req = *existing
+	return true, nil
+}
+
+// redeem counts one use of the invite and creates record in one transaction.
+func (r *inviteRepository) redeem(ctx context.Context, inviteID uuid.UUID, now time.Time, record interface{}) (bool, error) {
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ok, err := useInvite(tx, inviteID, now)
+		if err != nil || !ok {
+			return err
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+func useInvite(db *gorm.DB, inviteID uuid.UUID, now time.Time) (bool, error) {
+	result := db.
+		Model(&model.ConversationInvite{}).
+		Where("id = ? AND revoked_at IS NULL", inviteID).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Where("max_uses = 0 OR uses < max_uses").
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *inviteRepository) FindJoinRequest(ctx context.Context, conversationID, requestID uuid.UUID) (*model.JoinRequest, error) {
+	var req model.JoinRequest
+	if err := r.db.WithContext(ctx).Where("id = ? AND conversation_id = ?", requestID, conversationID).First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *inviteRepository) FindPendingJoinRequest(ctx context.Context, conversationID, userID uuid.UUID) (*model.JoinRequest, error) {
+	var req model.JoinRequest
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND user_id = ? AND status = ?", conversationID, userID, model.JoinRequestPending).
+		First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// FindPendingJoinRequests returns the requests awaiting a decision, oldest first.
+func (r *inviteRepository) FindPendingJoinRequests(ctx context.Context, conversationID uuid.UUID) ([]model.JoinRequest, error) {
+	var reqs []model.JoinRequest
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND status = ?", conversationID, model.JoinRequestPending).
+		Order("created_at ASC").
+		Find(&reqs).Error
+	return reqs, err
+}
+
+// DecideJoinRequest moves a pending request to status and updates req. It
+// returns false if the request was already decided.
+func (r *inviteRepository) DecideJoinRequest(ctx context.Context, req *model.JoinRequest, status string, decidedBy uuid.UUID) (bool, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.JoinRequest{}).
+		Where("id = ? AND status = ?", req.ID, model.JoinRequestPending).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"decided_by": decidedBy,
+			"decided_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	req.Status = status
+	req.DecidedBy = &decidedBy
+	req.DecidedAt = &now
+	return true, nil
+}
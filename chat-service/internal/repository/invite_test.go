@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestUseScopesOrToExpiry(t *testing.T) {
+	db, recorder := dryRunDB(t)
+
+	NewInviteRepository(db).Use(context.Background(), uuid.New(), time.Now())
+
+	sql := recorder.last(t)
+	if !strings.Contains(sql, "AND (expires_at IS NULL OR expires_at > ") {
+		t.Errorf("expiry check is not parenthesized:\n%s", sql)
+	}
+	if !strings.Contains(sql, "AND (max_uses = 0 OR uses < max_uses)") {
+		t.Errorf("max uses check is not parenthesized:\n%s", sql)
+	}
+}
+
+func createInvite(t *testing.T, ctx context.Context, repo InviteRepository, invite *model.ConversationInvite) {
+	t.Helper()
+	invite.ConversationID = uuid.New()
+	invite.CreatedBy = uuid.New()
+	invite.Token = strings.ReplaceAll(uuid.NewString(), "-", "")
+	if err := repo.Create(ctx, invite); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUseEnforcesMaxUses(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewInviteRepository(db)
+	now := time.Now()
+
+	invite := &model.ConversationInvite{MaxUses: 3}
+	createInvite(t, ctx, repo, invite)
+
+	// Concurrent accepts race for the last uses
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	used := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := repo.Use(ctx, invite.ID, now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				used++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if used != 3 {
+		t.Errorf("%d uses succeeded, want 3", used)
+	}
+	var stored model.ConversationInvite
+	if err := db.First(&stored, "id = ?", invite.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Uses != 3 {
+		t.Errorf("uses = %d, want 3", stored.Uses)
+	}
+}
+
+func TestUseRejectsUnusableInvites(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewInviteRepository(db)
+	now := time.Now()
+	past := now.Add(-time.Minute)
+
+	unlimited := &model.ConversationInvite{}
+	createInvite(t, ctx, repo, unlimited)
+	for i := 0; i < 5; i++ {
+		if ok, err := repo.Use(ctx, unlimited.ID, now); err != nil || !ok {
+			t.Fatalf("use %d of an unlimited invite = %v, %v", i+1, ok, err)
+		}
+	}
+
+	expired := &model.ConversationInvite{ExpiresAt: &past}
+	createInvite(t, ctx, repo, expired)
+	if ok, err := repo.Use(ctx, expired.ID, now); err != nil || ok {
+		t.Errorf("expired invite = %v, %v; want false", ok, err)
+	}
+
+	revoked := &model.ConversationInvite{RevokedAt: &past}
+	createInvite(t, ctx, repo, revoked)
+	if ok, err := repo.Use(ctx, revoked.ID, now); err != nil || ok {
+		t.Errorf("revoked invite = %v, %v; want false", ok, err)
+	}
+}
+
+func inviteUses(t *testing.T, db *gorm.DB, inviteID uuid.UUID) int {
+	t.Helper()
+	var invite model.ConversationInvite
+	if err := db.First(&invite, "id = ?", inviteID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return invite.Uses
+}
+
+func TestJoinKeepsUseOnFailedInsert(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewInviteRepository(db)
+	now := time.Now()
+
+	invite := &model.ConversationInvite{MaxUses: 1}
+	createInvite(t, ctx, repo, invite)
+	conv := &model.Conversation{ID: invite.ConversationID, Type: "group", Name: "invite join", CreatedBy: invite.CreatedBy}
+	if err := db.Create(conv).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	member := func(userID uuid.UUID) *model.ConversationMember {
+		return &model.ConversationMember{ConversationID: conv.ID, UserID: userID, Role: model.RoleMember}
+	}
+	existing := uuid.New()
+	if err := db.Create(member(existing)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Join(ctx, invite.ID, member(existing), now); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("Join of a member = %v, want ErrDuplicatedKey", err)
+	}
+	if uses := inviteUses(t, db, invite.ID); uses != 0 {
+		t.Errorf("failed Join used the invite: uses = %d", uses)
+	}
+
+	if ok, err := repo.Join(ctx, invite.ID, member(uuid.New()), now); err != nil || !ok {
+		t.Fatalf("Join = %v, %v; want true", ok, err)
+	}
+	if ok, err := repo.Join(ctx, invite.ID, member(uuid.New()), now); err != nil || ok {
+		t.Errorf("Join past max uses = %v, %v; want false", ok, err)
+	}
+}
+
+func TestRequestJoinReturnsPendingRequest(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewInviteRepository(db)
+	now := time.Now()
+
+	invite := &model.ConversationInvite{MaxUses: 2, RequiresApproval: true}
+	createInvite(t, ctx, repo, invite)
+	request := func(userID uuid.UUID) *model.JoinRequest {
+		return &model.JoinRequest{ConversationID: invite.ConversationID, UserID: userID, InviteID: invite.ID, Status: model.JoinRequestPending}
+	}
+
+	user := uuid.New()
+	first := request(user)
+	if ok, err := repo.RequestJoin(ctx, invite.ID, first, now); err != nil || !ok {
+		t.Fatalf("RequestJoin = %v, %v; want true", ok, err)
+	}
+
+	again := request(user)
+	if ok, err := repo.RequestJoin(ctx, invite.ID, again, now); err != nil || !ok {
+		t.Fatalf("repeated RequestJoin = %v, %v; want true", ok, err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("repeated RequestJoin filed %s, want the pending %s", again.ID, first.ID)
+	}
+	if uses := inviteUses(t, db, invite.ID); uses != 1 {
+		t.Errorf("uses = %d, want 1", uses)
+	}
+}
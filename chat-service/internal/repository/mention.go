@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// FindMentions returns up to limit live messages mentioning userID strictly
+// before cursor (or the latest ones if cursor is nil), newest first, and
+// whether more exist. Mentions in conversations the user has left or in
+// messages they hid are skipped.
+func (r *messageRepository) FindMentions(ctx context.Context, userID uuid.UUID, before *model.MessageCursor, limit int) ([]model.Message, bool, error) {
+	var messages []model.Message
+
+	q := r.db.WithContext(ctx).
+		Joins("JOIN message_mentions mm ON mm.message_id = messages.id").
+		Where("mm.user_id = ?", userID).
+		Where("EXISTS (SELECT 1 FROM conversation_members cm WHERE cm.conversation_id = mm.conversation_id AND cm.user_id = mm.user_id AND cm.deleted_at IS NULL)").
+		Where("NOT EXISTS (SELECT 1 FROM hidden_messages h WHERE h.message_id = messages.id AND h.user_id = mm.user_id)")
+	if before != nil {
+		q = q.Where("(mm.created_at, mm.message_id) < (?, ?)", before.CreatedAt, before.ID)
+	}
+
+	err := q.Order("mm.created_at DESC, mm.message_id DESC").
+		Limit(limit + 1).
+		Find(&messages).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	return messages, hasMore, nil
+}
+
+// FindMentionedUsers returns the users a message mentions.
+func (r *messageRepository) FindMentionedUsers(ctx context.Context, messageID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := r.db.WithContext(ctx).
+		Model(&model.MessageMention{}).
+		Where("message_id = ?", messageID).
+		Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
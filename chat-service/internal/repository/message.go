@@ -2,18 +2,41 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/chatmenow/chat-service/internal/model"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrDuplicateMessage is returned by Create when the sender already sent a
+// message with the same client message ID; msg is filled with the stored one.
+var ErrDuplicateMessage = errors.New("duplicate client message id")
+
 type MessageRepository interface {
-	Create(ctx context.Context, msg *model.Message) error
-	FindByConversation(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]model.Message, error)
+	Create(ctx context.Context, msg *model.Message, mentions []uuid.UUID) error
+	FindByConversation(ctx context.Context, conversationID, userID uuid.UUID, query model.MessageQuery) (*model.MessagePage, error)
+	FindThread(ctx context.Context, root *model.Message, userID uuid.UUID, query model.MessageQuery) (*model.MessagePage, error)
+	FindThreadParticipants(ctx context.Context, root *model.Message) ([]uuid.UUID, error)
 	FindByID(ctx context.Context, id uuid.UUID) (*model.Message, error)
+	FindBySeq(ctx context.Context, conversationID uuid.UUID, seq int64) (*model.Message, error)
+	FindAfterSeq(ctx context.Context, conversationID, userID uuid.UUID, afterSeq int64, limit int) ([]model.Message, error)
+	FindChangedSince(ctx context.Context, conversationID, userID uuid.UUID, maxSeq int64, since time.Time, limit int) ([]model.Message, error)
 	Update(ctx context.Context, msg *model.Message) error
+	Edit(ctx context.Context, msg *model.Message, content string, editedBy uuid.UUID) error
+	FindEdits(ctx context.Context, messageID uuid.UUID) ([]model.MessageEdit, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	Hide(ctx context.Context, messageID, userID uuid.UUID) error
+	AddReaction(ctx context.Context, reaction *model.MessageReaction, max int) (bool, error)
+	RemoveReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) (bool, error)
+	SummarizeReactions(ctx context.Context, messageIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]model.ReactionSummary, error)
+	FindMentions(ctx context.Context, userID uuid.UUID, before *model.MessageCursor, limit int) ([]model.Message, bool, error)
+	FindMentionedUsers(ctx context.Context, messageID uuid.UUID) ([]uuid.UUID, error)
+	Pin(ctx context.Context, pin *model.PinnedMessage, max int) (bool, error)
+	Unpin(ctx context.Context, conversationID, messageID uuid.UUID) (bool, error)
+	FindPins(ctx context.Context, conversationID uuid.UUID) ([]model.PinnedMessage, error)
 }
 
 type messageRepository struct {
@@ -24,30 +47,253 @@ func NewMessageRepository(db *gorm.DB) MessageRepository {
 	return &messageRepository{db: db}
 }
 
-func (r *messageRepository) Create(ctx context.Context, msg *model.Message) error {
-	return r.db.WithContext(ctx).Create(msg).Error
-}
+// Create inserts msg and assigns it the conversation's next sequence number,
+// recording the users it mentions and claiming its attachments. Bumping
+// conversations.last_seq row-locks the conversation until commit, so
+// concurrent sends are serialized and a rolled-back insert leaves no gap.
+func (r *messageRepository) Create(ctx context.Context, msg *model.Message, mentions []uuid.UUID) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var seq int64
+		err := tx.Raw(
+			"UPDATE conversations SET last_seq = last_seq + 1 WHERE id = ? AND deleted_at IS NULL RETURNING last_seq",
+			msg.ConversationID,
+		).Scan(&seq).Error
+		if err != nil {
+			return err
+		}
+		if seq == 0 {
+			return gorm.ErrRecordNotFound
+		}
 
-func (r *messageRepository) FindByConversation(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]model.Message, error) {
-	var messages []model.Message
+		// A retry of a message we already stored; checked under the conversation
+		// lock so it cannot race with the original insert.
+		if msg.ClientMsgID != nil {
+			existing, err := r.findByClientMsgID(tx, msg)
+			if err == nil {
+				*msg = *existing
+				return ErrDuplicateMessage
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
 
-	err := r.db.WithContext(ctx).
-		Where("conversation_id = ?", conversationID).
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error
+		msg.Seq = seq
+		if err := tx.Create(msg).Error; err != nil {
+			return err
+		}
+
+		// Forwarded copies point at attachments already sent with the original
+		if len(msg.AttachmentIDs) > 0 && msg.ForwardedFrom == nil {
+			if err := linkAttachments(tx, msg); err != nil {
+				return err
+			}
+		}
+
+		if len(mentions) > 0 {
+			rows := make([]model.MessageMention, len(mentions))
+			for i, userID := range mentions {
+				rows[i] = model.MessageMention{
+					MessageID:      msg.ID,
+					UserID:         userID,
+					ConversationID: msg.ConversationID,
+					CreatedAt:      msg.CreatedAt,
+				}
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+
+		if msg.ThreadRootID != nil {
+			return tx.Model(&model.Message{}).
+				Where("id = ?", *msg.ThreadRootID).
+				UpdateColumns(map[string]interface{}{
+					"thread_reply_count":   gorm.Expr("thread_reply_count + 1"),
+					"thread_last_reply_at": msg.CreatedAt,
+				}).Error
+		}
+		return nil
+	})
+
+	// Lost a race with a concurrent insert of the same client message ID
+	if errors.Is(err, gorm.ErrDuplicatedKey) && msg.ClientMsgID != nil {
+		existing, findErr := r.findByClientMsgID(r.db.WithContext(ctx), msg)
+		if findErr != nil {
+			return err
+		}
+		*msg = *existing
+		return ErrDuplicateMessage
+	}
+
+	return err
+}
 
+func (r *messageRepository) findByClientMsgID(db *gorm.DB, msg *model.Message) (*model.Message, error) {
+	var existing model.Message
+	err := db.Unscoped().
+		Where("conversation_id = ? AND sender_id = ? AND client_msg_id = ?", msg.ConversationID, msg.SenderID, *msg.ClientMsgID).
+		First(&existing).Error
 	if err != nil {
 		return nil, err
 	}
+	return &existing, nil
+}
+
+// history scopes messages to a conversation as seen by userID: messages deleted
+// for everyone come back as tombstones, messages the user hid are skipped.
+func (r *messageRepository) history(ctx context.Context, conversationID, userID uuid.UUID) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Unscoped().
+		Where("conversation_id = ?", conversationID).
+		Where("NOT EXISTS (SELECT 1 FROM hidden_messages h WHERE h.message_id = messages.id AND h.user_id = ?)", userID)
+}
+
+// olderThan returns up to limit messages of scope strictly before cursor (or
+// the latest ones if cursor is nil) in chronological order, and whether more exist.
+func olderThan(scope func() *gorm.DB, cursor *model.MessageCursor, limit int) ([]model.Message, bool, error) {
+	var messages []model.Message
+
+	q := scope()
+	if cursor != nil {
+		q = q.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	if err := q.Order("created_at DESC, id DESC").Limit(limit + 1).Find(&messages).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
 
 	// Reverse to get chronological order (oldest first)
 	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
 		messages[i], messages[j] = messages[j], messages[i]
 	}
 
-	return messages, nil
+	return messages, hasMore, nil
+}
+
+// newerThan returns up to limit messages of scope strictly after cursor in
+// chronological order, and whether more exist.
+func newerThan(scope func() *gorm.DB, cursor *model.MessageCursor, limit int) ([]model.Message, bool, error) {
+	var messages []model.Message
+
+	err := scope().
+		Where("(created_at, id) > (?, ?)", cursor.CreatedAt, cursor.ID).
+		Order("created_at ASC, id ASC").
+		Limit(limit + 1).
+		Find(&messages).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	return messages, hasMore, nil
+}
+
+// paginate returns one page of scope using keyset pagination over
+// (created_at, id). scope is called once per query it needs.
+func (r *messageRepository) paginate(ctx context.Context, scope func() *gorm.DB, userID uuid.UUID, query model.MessageQuery) (*model.MessagePage, error) {
+	var (
+		messages           []model.Message
+		hasOlder, hasNewer bool
+		err                error
+	)
+
+	switch {
+	case query.After != nil:
+		messages, hasNewer, err = newerThan(scope, query.After, query.Limit)
+		hasOlder = true
+
+	case query.Around != nil:
+		var target model.Message
+		if err = scope().Where("id = ?", *query.Around).First(&target).Error; err != nil {
+			return nil, err
+		}
+
+		cursor := model.CursorFor(&target)
+		olderLimit := (query.Limit - 1) / 2
+		newerLimit := query.Limit - 1 - olderLimit
+
+		var older, newer []model.Message
+		if older, hasOlder, err = olderThan(scope, cursor, olderLimit); err != nil {
+			return nil, err
+		}
+		if newer, hasNewer, err = newerThan(scope, cursor, newerLimit); err != nil {
+			return nil, err
+		}
+
+		messages = append(append(older, target), newer...)
+
+	default:
+		messages, hasOlder, err = olderThan(scope, query.Before, query.Limit)
+		hasNewer = query.Before != nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.attachReactions(ctx, messages, userID); err != nil {
+		return nil, err
+	}
+
+	page := &model.MessagePage{Messages: messages}
+	if len(messages) > 0 {
+		if hasOlder {
+			page.NextCursor = model.CursorFor(&messages[0]).Encode()
+		}
+		if hasNewer {
+			page.PrevCursor = model.CursorFor(&messages[len(messages)-1]).Encode()
+		}
+	}
+	if page.Messages == nil {
+		page.Messages = []model.Message{}
+	}
+
+	return page, nil
+}
+
+// FindByConversation returns one page of the conversation's main timeline;
+// thread replies are only listed by FindThread. Served by
+// idx_messages_conversation_created.
+func (r *messageRepository) FindByConversation(ctx context.Context, conversationID, userID uuid.UUID, query model.MessageQuery) (*model.MessagePage, error) {
+	return r.paginate(ctx, func() *gorm.DB {
+		return r.history(ctx, conversationID, userID).Where("thread_root_id IS NULL")
+	}, userID, query)
+}
+
+// FindThread returns one page of the replies to a thread root.
+func (r *messageRepository) FindThread(ctx context.Context, root *model.Message, userID uuid.UUID, query model.MessageQuery) (*model.MessagePage, error) {
+	return r.paginate(ctx, func() *gorm.DB {
+		return r.history(ctx, root.ConversationID, userID).Where("thread_root_id = ?", root.ID)
+	}, userID, query)
+}
+
+// FindThreadParticipants returns the root's sender and everyone who replied
+// in its thread.
+func (r *messageRepository) FindThreadParticipants(ctx context.Context, root *model.Message) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := r.db.WithContext(ctx).
+		Model(&model.Message{}).
+		Distinct("sender_id").
+		Where("thread_root_id = ?", root.ID).
+		Pluck("sender_id", &userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range userIDs {
+		if id == root.SenderID {
+			return userIDs, nil
+		}
+	}
+	return append(userIDs, root.SenderID), nil
 }
 
 func (r *messageRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Message, error) {
@@ -59,10 +305,156 @@ func (r *messageRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.
 	return &message, nil
 }
 
+func (r *messageRepository) FindBySeq(ctx context.Context, conversationID uuid.UUID, seq int64) (*model.Message, error) {
+	var message model.Message
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		First(&message, "conversation_id = ? AND seq = ?", conversationID, seq).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// FindAfterSeq returns up to limit messages with seq > afterSeq in seq order,
+// as seen by userID (tombstones included, hidden messages skipped).
+func (r *messageRepository) FindAfterSeq(ctx context.Context, conversationID, userID uuid.UUID, afterSeq int64, limit int) ([]model.Message, error) {
+	var messages []model.Message
+	err := r.history(ctx, conversationID, userID).
+		Where("seq > ?", afterSeq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// FindChangedSince returns up to limit messages up to maxSeq that were edited
+// or deleted after since, in seq order.
+func (r *messageRepository) FindChangedSince(ctx context.Context, conversationID, userID uuid.UUID, maxSeq int64, since time.Time, limit int) ([]model.Message, error) {
+	var messages []model.Message
+	err := r.history(ctx, conversationID, userID).
+		Where("seq <= ?", maxSeq).
+		Where("edited_at > ? OR deleted_at > ?", since, since).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
 func (r *messageRepository) Update(ctx context.Context, msg *model.Message) error {
 	return r.db.WithContext(ctx).Save(msg).Error
 }
 
+// Edit stores the current content as an edit history entry and replaces it
+// with the new content in a single transaction.
+func (r *messageRepository) Edit(ctx context.Context, msg *model.Message, content string, editedBy uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		edit := &model.MessageEdit{
+			MessageID: msg.ID,
+			Content:   msg.Content,
+			EditedBy:  editedBy,
+		}
+		if err := tx.Create(edit).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(msg).Updates(map[string]interface{}{
+			"content":   content,
+			"edited_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		msg.Content = content
+		msg.EditedAt = &now
+		return nil
+	})
+}
+
+func (r *messageRepository) FindEdits(ctx context.Context, messageID uuid.UUID) ([]model.MessageEdit, error) {
+	var edits []model.MessageEdit
+	err := r.db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Order("created_at DESC").
+		Find(&edits).Error
+	if err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// Delete tombstones a message: its content and attachment references are wiped
+// and it is soft-deleted so it no longer resolves by ID but still holds its
+// place in the history. A thread reply stops counting toward its root, and
+// the message's pins are removed.
 func (r *messageRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.Message{}, "id = ?", id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Only a live reply is uncounted, so deleting twice can't drift
+		err := tx.Exec(`UPDATE messages root
+			SET thread_reply_count = GREATEST(root.thread_reply_count - 1, 0)
+			FROM messages reply
+			WHERE reply.id = ? AND reply.thread_root_id IS NOT NULL AND reply.deleted_at IS NULL
+				AND root.id = reply.thread_root_id`, id).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("message_id = ?", id).Delete(&model.PinnedMessage{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Message{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"content":        "",
+				"metadata":       gorm.Expr("NULL"),
+				"attachment_ids": gorm.Expr("NULL"),
+				"deleted_at":     time.Now(),
+			}).Error
+	})
+}
+
+func (r *messageRepository) Hide(ctx context.Context, messageID, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.HiddenMessage{MessageID: messageID, UserID: userID}).Error
+}
+
+// BackfillMessageSeq numbers the history of a database created before
+// messages had a seq, as migrations/005_message_seq.sql does. It must run
+// before AutoMigrate, which would otherwise add seq as all zeros and then fail
+// to build the unique (conversation_id, seq) index. It does nothing once that
+// index exists.
+func BackfillMessageSeq(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&model.Message{}) || migrator.HasIndex(&model.Message{}, "idx_messages_conversation_seq") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"ALTER TABLE conversations ADD COLUMN IF NOT EXISTS last_seq BIGINT NOT NULL DEFAULT 0",
+			"ALTER TABLE messages ADD COLUMN IF NOT EXISTS seq BIGINT NOT NULL DEFAULT 0",
+			`UPDATE messages m SET seq = o.rn
+			FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS rn
+				FROM messages
+			) o
+			WHERE m.id = o.id`,
+			"UPDATE conversations c SET last_seq = COALESCE((SELECT MAX(seq) FROM messages m WHERE m.conversation_id = c.id), 0)",
+		}
+		for _, sql := range statements {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
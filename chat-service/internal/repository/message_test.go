@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFindChangedSinceIsBounded(t *testing.T) {
+	db, recorder := dryRunDB(t)
+	repo := NewMessageRepository(db)
+
+	repo.FindChangedSince(context.Background(), uuid.New(), uuid.New(), 42, time.Now(), 201)
+
+	sql := recorder.last(t)
+	if !strings.Contains(sql, "LIMIT 201") {
+		t.Errorf("changed messages are not limited:\n%s", sql)
+	}
+	if !strings.Contains(sql, "(edited_at > ") {
+		t.Errorf("edited/deleted check is not parenthesized:\n%s", sql)
+	}
+}
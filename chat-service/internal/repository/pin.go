@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPinLimit is returned by Pin when the conversation already has the
+// maximum number of pinned messages.
+var ErrPinLimit = errors.New("pin limit reached")
+
+// Pin pins a message, reporting false if it was already pinned. With max > 0
+// it returns ErrPinLimit once the conversation has max pins of live messages;
+// the conversation row is locked while counting so concurrent pins can't
+// overshoot.
+func (r *messageRepository) Pin(ctx context.Context, pin *model.PinnedMessage, max int) (bool, error) {
+	added := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&model.Conversation{}, "id = ?", pin.ConversationID).Error
+		if err != nil {
+			return err
+		}
+
+		var existing int64
+		err = tx.Model(&model.PinnedMessage{}).
+			Where("conversation_id = ? AND message_id = ?", pin.ConversationID, pin.MessageID).
+			Count(&existing).Error
+		if err != nil || existing > 0 {
+			return err
+		}
+
+		if max > 0 {
+			var count int64
+			err := tx.Model(&model.PinnedMessage{}).
+				Joins("JOIN messages m ON m.id = pinned_messages.message_id AND m.deleted_at IS NULL").
+				Where("pinned_messages.conversation_id = ?", pin.ConversationID).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count >= int64(max) {
+				return ErrPinLimit
+			}
+		}
+
+		if err := tx.Omit("Message").Create(pin).Error; err != nil {
+			return err
+		}
+		added = true
+		return nil
+	})
+	return added, err
+}
+
+// Unpin removes a pin, reporting false if the message wasn't pinned.
+func (r *messageRepository) Unpin(ctx context.Context, conversationID, messageID uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND message_id = ?", conversationID, messageID).
+		Delete(&model.PinnedMessage{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindPins returns the conversation's pinned messages, most recently pinned
+// first. Pins of messages deleted for everyone are left out.
+func (r *messageRepository) FindPins(ctx context.Context, conversationID uuid.UUID) ([]model.PinnedMessage, error) {
+	var pins []model.PinnedMessage
+	err := r.db.WithContext(ctx).
+		InnerJoins("Message").
+		Where("pinned_messages.conversation_id = ?", conversationID).
+		Order("pinned_messages.pinned_at DESC").
+		Find(&pins).Error
+	return pins, err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestDeletedMessagesFreePinSlots(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewMessageRepository(db)
+
+	user := uuid.New()
+	conv := &model.Conversation{Type: "group", Name: "pins", CreatedBy: user}
+	if err := db.Create(conv).Error; err != nil {
+		t.Fatal(err)
+	}
+	send := func() *model.Message {
+		msg := &model.Message{ConversationID: conv.ID, SenderID: user, Content: "pin me", Type: "text"}
+		if err := repo.Create(ctx, msg, nil); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	pin := func(msg *model.Message) error {
+		_, err := repo.Pin(ctx, &model.PinnedMessage{ConversationID: conv.ID, MessageID: msg.ID, PinnedBy: user}, 2)
+		return err
+	}
+
+	first, second := send(), send()
+	for _, msg := range []*model.Message{first, second} {
+		if err := pin(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pin(send()); !errors.Is(err, ErrPinLimit) {
+		t.Fatalf("third pin err = %v, want ErrPinLimit", err)
+	}
+
+	if err := repo.Delete(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	var left int64
+	db.Model(&model.PinnedMessage{}).Where("message_id = ?", first.ID).Count(&left)
+	if left != 0 {
+		t.Errorf("deleted message still has %d pins", left)
+	}
+
+	// A pin left behind by an older deletion doesn't count either
+	ghost := send()
+	if err := db.Create(&model.PinnedMessage{ConversationID: conv.ID, MessageID: ghost.ID, PinnedBy: user}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&model.Message{}).Where("id = ?", ghost.ID).Update("deleted_at", ghost.CreatedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pin(send()); err != nil {
+		t.Errorf("pin after deletion = %v, want nil", err)
+	}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReactionLimit is returned by AddReaction when the user already has the
+// maximum number of different reactions on the message.
+var ErrReactionLimit = errors.New("reaction limit reached")
+
+// AddReaction stores a reaction, reporting false if it already existed. With
+// max > 0 it returns ErrReactionLimit once the user has max reactions on the
+// message; the message row is locked while counting so concurrent reactions
+// can't overshoot.
+func (r *messageRepository) AddReaction(ctx context.Context, reaction *model.MessageReaction, max int) (bool, error) {
+	added := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&model.Message{}, "id = ?", reaction.MessageID).Error
+		if err != nil {
+			return err
+		}
+
+		var emoji []string
+		err = tx.Model(&model.MessageReaction{}).
+			Where("message_id = ? AND user_id = ?", reaction.MessageID, reaction.UserID).
+			Pluck("emoji", &emoji).Error
+		if err != nil {
+			return err
+		}
+		for _, e := range emoji {
+			if e == reaction.Emoji {
+				return nil
+			}
+		}
+		if max > 0 && len(emoji) >= max {
+			return ErrReactionLimit
+		}
+
+		if err := tx.Create(reaction).Error; err != nil {
+			return err
+		}
+		added = true
+		return nil
+	})
+	return added, err
+}
+
+// RemoveReaction deletes a reaction, reporting false if there was none.
+func (r *messageRepository) RemoveReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&model.MessageReaction{})
+	return result.RowsAffected > 0, result.Error
+}
+
+type reactionRow struct {
+	MessageID uuid.UUID
+	Emoji     string
+	Count     int64
+	Reacted   bool
+}
+
+// SummarizeReactions aggregates reactions per message and emoji, in the order
+// each emoji was first used. Reacted is computed for userID (uuid.Nil for none).
+func (r *messageRepository) SummarizeReactions(ctx context.Context, messageIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]model.ReactionSummary, error) {
+	summaries := make(map[uuid.UUID][]model.ReactionSummary)
+	if len(messageIDs) == 0 {
+		return summaries, nil
+	}
+
+	var rows []reactionRow
+	err := r.db.WithContext(ctx).
+		Model(&model.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("MIN(created_at)").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		summaries[row.MessageID] = append(summaries[row.MessageID], model.ReactionSummary{
+			Emoji:   row.Emoji,
+			Count:   row.Count,
+			Reacted: row.Reacted,
+		})
+	}
+	return summaries, nil
+}
+
+// attachReactions fills in the reaction summaries of a page of messages.
+func (r *messageRepository) attachReactions(ctx context.Context, messages []model.Message, userID uuid.UUID) error {
+	ids := make([]uuid.UUID, 0, len(messages))
+	for _, m := range messages {
+		if !m.Deleted {
+			ids = append(ids, m.ID)
+		}
+	}
+
+	summaries, err := r.SummarizeReactions(ctx, ids, userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		messages[i].Reactions = summaries[messages[i].ID]
+	}
+	return nil
+}
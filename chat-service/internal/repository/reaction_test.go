@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestAddReactionLimit(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewMessageRepository(db)
+
+	user := uuid.New()
+	conv := &model.Conversation{Type: "group", Name: "reactions", CreatedBy: user}
+	if err := db.Create(conv).Error; err != nil {
+		t.Fatal(err)
+	}
+	msg := &model.Message{ConversationID: conv.ID, SenderID: user, Content: "hi", Type: "text"}
+	if err := repo.Create(ctx, msg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	react := func(userID uuid.UUID, emoji string) (bool, error) {
+		return repo.AddReaction(ctx, &model.MessageReaction{MessageID: msg.ID, UserID: userID, Emoji: emoji}, 2)
+	}
+
+	for _, emoji := range []string{"👍", "🎉"} {
+		if added, err := react(user, emoji); err != nil || !added {
+			t.Fatalf("AddReaction(%s) = %v, %v; want true", emoji, added, err)
+		}
+	}
+	if added, err := react(user, "👍"); err != nil || added {
+		t.Errorf("repeated reaction = %v, %v; want false, nil", added, err)
+	}
+	if _, err := react(user, "🔥"); !errors.Is(err, ErrReactionLimit) {
+		t.Errorf("third emoji err = %v, want ErrReactionLimit", err)
+	}
+	if added, err := react(uuid.New(), "🔥"); err != nil || !added {
+		t.Errorf("another user's reaction = %v, %v; want true", added, err)
+	}
+}
@@ -19,17 +19,92 @@ func NewRedisClient(addr string) *RedisClient {
 	return &RedisClient{client: client}
 }
 
-func (r *RedisClient) SetUserOnline(ctx context.Context, userID string) error {
-	return r.client.Set(ctx, "user:"+userID+":online", time.Now().Unix(), 0).Err()
+// SessionTTL is how long a presence session survives without a refresh. A
+// replica that dies without disconnecting its clients stops refreshing, so
+// its sessions expire on their own.
+const SessionTTL = 2 * time.Minute
+
+func sessionsKey(userID string) string {
+	return "user:" + userID + ":sessions"
+}
+
+func sessionKey(userID, sessionID string) string {
+	return "user:" + userID + ":session:" + sessionID
+}
+
+// Presence is tracked per live session so that several devices, possibly
+// connected to different replicas, can keep a user online. Each session has
+// its own expiring key; the per-user set only indexes them.
+func (r *RedisClient) SetUserOnline(ctx context.Context, userID, sessionID string) error {
+	pipe := r.client.TxPipeline()
+	touchSession(ctx, pipe, userID, sessionID)
+	pipe.Set(ctx, "user:"+userID+":last_seen", time.Now().Unix(), 0)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisClient) SetUserOffline(ctx context.Context, userID, sessionID string) error {
+	pipe := r.client.TxPipeline()
+	pipe.SRem(ctx, sessionsKey(userID), sessionID)
+	pipe.Del(ctx, sessionKey(userID, sessionID))
+	pipe.Set(ctx, "user:"+userID+":last_seen", time.Now().Unix(), 0)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RefreshSessions extends the TTL of live sessions, given as user ID ->
+// session IDs.
+func (r *RedisClient) RefreshSessions(ctx context.Context, sessions map[string][]string) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for userID, sessionIDs := range sessions {
+		for _, sessionID := range sessionIDs {
+			touchSession(ctx, pipe, userID, sessionID)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-func (r *RedisClient) SetUserOffline(ctx context.Context, userID string) error {
-	return r.client.Del(ctx, "user:"+userID+":online").Err()
+func touchSession(ctx context.Context, pipe redis.Pipeliner, userID, sessionID string) {
+	pipe.Set(ctx, sessionKey(userID, sessionID), 1, SessionTTL)
+	pipe.SAdd(ctx, sessionsKey(userID), sessionID)
+	pipe.Expire(ctx, sessionsKey(userID), SessionTTL)
 }
 
+// IsUserOnline reports whether any of the user's sessions is still live,
+// dropping expired ones from the index.
 func (r *RedisClient) IsUserOnline(ctx context.Context, userID string) (bool, error) {
-	exists, err := r.client.Exists(ctx, "user:"+userID+":online").Result()
-	return exists > 0, err
+	sessionIDs, err := r.client.SMembers(ctx, sessionsKey(userID)).Result()
+	if err != nil || len(sessionIDs) == 0 {
+		return false, err
+	}
+
+	pipe := r.client.Pipeline()
+	live := make([]*redis.IntCmd, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		live[i] = pipe.Exists(ctx, sessionKey(userID, sessionID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	online := false
+	var expired []interface{}
+	for i, cmd := range live {
+		if cmd.Val() > 0 {
+			online = true
+		} else {
+			expired = append(expired, sessionIDs[i])
+		}
+	}
+	if len(expired) > 0 {
+		r.client.SRem(ctx, sessionsKey(userID), expired...)
+	}
+	return online, nil
 }
 
 func (r *RedisClient) AddTyping(ctx context.Context, conversationID, userID string) error {
@@ -47,6 +122,14 @@ func (r *RedisClient) GetTypingUsers(ctx context.Context, conversationID string)
 	return r.client.SMembers(ctx, key).Result()
 }
 
+func (r *RedisClient) Publish(ctx context.Context, channel string, payload []byte) error {
+	return r.client.Publish(ctx, channel, payload).Err()
+}
+
+func (r *RedisClient) PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub {
+	return r.client.PSubscribe(ctx, patterns...)
+}
+
 func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
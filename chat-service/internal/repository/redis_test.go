@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := NewRedisClient(mr.Addr())
+	t.Cleanup(func() { client.Close() })
+	return client, mr
+}
+
+func TestPresenceSessions(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+
+	if err := r.SetUserOnline(ctx, "u1", "phone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetUserOnline(ctx, "u1", "laptop"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.SetUserOffline(ctx, "u1", "phone"); err != nil {
+		t.Fatal(err)
+	}
+	if online, err := r.IsUserOnline(ctx, "u1"); err != nil || !online {
+		t.Fatalf("online with one session left = %v, %v; want true", online, err)
+	}
+
+	if err := r.SetUserOffline(ctx, "u1", "laptop"); err != nil {
+		t.Fatal(err)
+	}
+	if online, err := r.IsUserOnline(ctx, "u1"); err != nil || online {
+		t.Fatalf("online after last session = %v, %v; want false", online, err)
+	}
+}
+
+func TestPresenceSessionsExpireWithoutRefresh(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	// u1's replica keeps refreshing, u2's replica has crashed
+	r.SetUserOnline(ctx, "u1", "s1")
+	r.SetUserOnline(ctx, "u2", "s2")
+
+	mr.FastForward(SessionTTL / 2)
+	if err := r.RefreshSessions(ctx, map[string][]string{"u1": {"s1"}}); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(SessionTTL/2 + 1)
+
+	if online, _ := r.IsUserOnline(ctx, "u1"); !online {
+		t.Error("refreshed session expired")
+	}
+	if online, _ := r.IsUserOnline(ctx, "u2"); online {
+		t.Error("unrefreshed session still online")
+	}
+}
+
+func TestIsUserOnlinePrunesExpiredSessions(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	r.SetUserOnline(ctx, "u1", "stale")
+	mr.Del(sessionKey("u1", "stale"))
+	r.SetUserOnline(ctx, "u1", "live")
+
+	if online, _ := r.IsUserOnline(ctx, "u1"); !online {
+		t.Fatal("want online")
+	}
+	members, _ := mr.Members(sessionsKey("u1"))
+	if len(members) != 1 || members[0] != "live" {
+		t.Errorf("sessions = %v, want [live]", members)
+	}
+}
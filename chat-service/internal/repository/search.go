@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"gorm.io/gorm"
+)
+
+// MessageSearcher runs full-text queries over message history. It only returns
+// live messages from conversations the querying user belongs to. The Postgres
+// implementation can be replaced by a dedicated search engine.
+type MessageSearcher interface {
+	Search(ctx context.Context, query model.SearchQuery) ([]model.SearchResult, bool, error)
+}
+
+// searchConfig is the text search configuration used for both the index and
+// queries. "simple" does no stemming, which works for any language.
+const searchConfig = "simple"
+
+// SearchIndexSQL creates the GIN index used by the Postgres searcher. GORM
+// cannot declare expression indexes, so it is run after AutoMigrate.
+const SearchIndexSQL = "CREATE INDEX IF NOT EXISTS idx_messages_content_fts ON messages USING GIN (to_tsvector('" + searchConfig + "', content))"
+
+// ts_headline marks matches with these control characters instead of <mark>
+// so the snippet can be HTML-escaped before the tags are put in. They are
+// stripped from the content first so a message can't forge its own marks.
+const (
+	highlightStart   = "\x02"
+	highlightStop    = "\x03"
+	highlightOptions = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", MaxFragments=2, MaxWords=20, MinWords=5`
+)
+
+var highlightReplacer = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// escapeHighlight HTML-escapes a ts_headline snippet and turns its match
+// markers into <mark></mark>.
+func escapeHighlight(s string) string {
+	return highlightReplacer.Replace(html.EscapeString(s))
+}
+
+type postgresSearcher struct {
+	db *gorm.DB
+}
+
+func NewPostgresSearcher(db *gorm.DB) MessageSearcher {
+	return &postgresSearcher{db: db}
+}
+
+type searchRow struct {
+	model.Message
+	Highlight string
+}
+
+// Search matches content against idx_messages_content_fts and returns up to
+// query.Limit results newest first, and whether more exist.
+func (s *postgresSearcher) Search(ctx context.Context, query model.SearchQuery) ([]model.SearchResult, bool, error) {
+	tsquery := "websearch_to_tsquery('" + searchConfig + "', ?)"
+
+	q := s.db.WithContext(ctx).
+		Model(&model.Message{}).
+		Select("messages.*, ts_headline('"+searchConfig+"', translate(content, ?, ''), "+tsquery+", ?) AS highlight",
+			highlightStart+highlightStop, query.Query, highlightOptions).
+		Where("to_tsvector('"+searchConfig+"', content) @@ "+tsquery, query.Query).
+		Where("type <> ?", model.MessageTypeSystem).
+		Where("EXISTS (SELECT 1 FROM conversation_members cm WHERE cm.conversation_id = messages.conversation_id AND cm.user_id = ? AND cm.deleted_at IS NULL)", query.UserID).
+		Where("NOT EXISTS (SELECT 1 FROM hidden_messages h WHERE h.message_id = messages.id AND h.user_id = ?)", query.UserID)
+
+	if query.ConversationID != nil {
+		q = q.Where("conversation_id = ?", *query.ConversationID)
+	}
+	if query.SenderID != nil {
+		q = q.Where("sender_id = ?", *query.SenderID)
+	}
+	if query.From != nil {
+		q = q.Where("created_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		q = q.Where("created_at < ?", *query.To)
+	}
+	if query.Type != "" {
+		q = q.Where("type = ?", query.Type)
+	}
+	if query.Before != nil {
+		q = q.Where("(created_at, id) < (?, ?)", query.Before.CreatedAt, query.Before.ID)
+	}
+
+	var rows []searchRow
+	err := q.Order("created_at DESC, id DESC").
+		Limit(query.Limit + 1).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(rows) > query.Limit
+	if hasMore {
+		rows = rows[:query.Limit]
+	}
+
+	results := make([]model.SearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = model.SearchResult{Message: row.Message, Highlight: escapeHighlight(row.Highlight)}
+	}
+	return results, hasMore, nil
+}
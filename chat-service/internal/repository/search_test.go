@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestEscapeHighlight(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"ready to \x02deploy\x03 on friday", "ready to <mark>deploy</mark> on friday"},
+		{"<img src=x onerror=alert(1)> \x02deploy\x03", "&lt;img src=x onerror=alert(1)&gt; <mark>deploy</mark>"},
+		{"<mark>fake</mark> & \"quoted\"", "&lt;mark&gt;fake&lt;/mark&gt; &amp; &#34;quoted&#34;"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := escapeHighlight(tt.in); got != tt.want {
+			t.Errorf("escapeHighlight(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchHighlightIsEscaped(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	user := uuid.New()
+	conv := &model.Conversation{Type: "group", Name: "search", CreatedBy: user}
+	if err := db.Create(conv).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.ConversationMember{ConversationID: conv.ID, UserID: user}).Error; err != nil {
+		t.Fatal(err)
+	}
+	term := "xss" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	content := "<script>alert(1)</script> \x02forged\x03 " + term
+	msg := &model.Message{ConversationID: conv.ID, SenderID: user, Content: content, Type: "text"}
+	if err := NewMessageRepository(db).Create(ctx, msg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	results, _, err := NewPostgresSearcher(db).Search(ctx, model.SearchQuery{UserID: user, Query: term, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	highlight := results[0].Highlight
+	if strings.Contains(highlight, "<script>") {
+		t.Errorf("highlight is not escaped: %q", highlight)
+	}
+	if !strings.Contains(highlight, "<mark>"+term+"</mark>") {
+		t.Errorf("highlight does not mark the match: %q", highlight)
+	}
+	if strings.Count(highlight, "<mark>") != 1 {
+		t.Errorf("content forged a mark: %q", highlight)
+	}
+}
+
+func TestSearchHighlightUsesSentinels(t *testing.T) {
+	db, recorder := dryRunDB(t)
+
+	NewPostgresSearcher(db).Search(context.Background(), model.SearchQuery{UserID: uuid.New(), Query: "deploy", Limit: 10})
+
+	sql := recorder.last(t)
+	if strings.Contains(sql, "<mark>") {
+		t.Errorf("ts_headline emits HTML tags:\n%s", sql)
+	}
+	if !strings.Contains(sql, "translate(content, '\x02\x03', '')") {
+		t.Errorf("sentinels are not stripped from content:\n%s", sql)
+	}
+}
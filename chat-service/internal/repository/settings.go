@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// FindSettings returns userID's settings for a conversation, or nil if they
+// never changed them.
+func (r *conversationRepository) FindSettings(ctx context.Context, conversationID, userID uuid.UUID) (*model.ConversationSettings, error) {
+	var settings []model.ConversationSettings
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Limit(1).
+		Find(&settings).Error
+	if err != nil || len(settings) == 0 {
+		return nil, err
+	}
+	return &settings[0], nil
+}
+
+// findSettings returns userID's stored settings for each of the conversations.
+func (r *conversationRepository) findSettings(ctx context.Context, userID uuid.UUID, conversationIDs []uuid.UUID) (map[uuid.UUID]*model.ConversationSettings, error) {
+	var settings []model.ConversationSettings
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND conversation_id IN ?", userID, conversationIDs).
+		Find(&settings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byConversation := make(map[uuid.UUID]*model.ConversationSettings, len(settings))
+	for i := range settings {
+		byConversation[settings[i].ConversationID] = &settings[i]
+	}
+	return byConversation, nil
+}
+
+// FindMembersSettings returns the stored settings of the given members of a
+// conversation. Members without a row are absent from the result.
+func (r *conversationRepository) FindMembersSettings(ctx context.Context, conversationID uuid.UUID, userIDs []uuid.UUID) ([]model.ConversationSettings, error) {
+	var settings []model.ConversationSettings
+	if len(userIDs) == 0 {
+		return settings, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("conversation_id = ? AND user_id IN ?", conversationID, userIDs).
+		Find(&settings).Error
+	return settings, err
+}
+
+// SaveSettings inserts or replaces a member's settings.
+func (r *conversationRepository) SaveSettings(ctx context.Context, settings *model.ConversationSettings) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(settings).Error
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/repository"
+	"github.com/chatmenow/chat-service/internal/storage"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxMessageAttachments caps how many attachments one message can carry.
+const maxMessageAttachments = 10
+
+var (
+	ErrAttachmentTooLarge = errors.New("attachment exceeds the maximum size")
+	ErrUnsupportedType    = errors.New("attachment type is not allowed")
+	ErrInvalidAttachment  = errors.New("attachments must be at most 10 of your own unsent uploads to this conversation")
+)
+
+// AttachmentLimits constrain uploads and downloads.
+type AttachmentLimits struct {
+	MaxSize      int64         // bytes
+	AllowedTypes []string      // media types, "image/*" allows a whole family
+	URLTTL       time.Duration // lifetime of signed download URLs
+}
+
+type AttachmentService struct {
+	repo   repository.AttachmentRepository
+	store  storage.BlobStore
+	limits AttachmentLimits
+}
+
+func NewAttachmentService(repo repository.AttachmentRepository, store storage.BlobStore, limits AttachmentLimits) *AttachmentService {
+	return &AttachmentService{
+		repo:   repo,
+		store:  store,
+		limits: limits,
+	}
+}
+
+// MaxSize is the largest upload accepted, in bytes.
+func (s *AttachmentService) MaxSize() int64 {
+	return s.limits.MaxSize
+}
+
+// Upload stores a file for uploaderID to send in a conversation. The content
+// type is sniffed from the data; the declared one is only used when sniffing
+// can't tell. Callers check PermPost first.
+func (s *AttachmentService) Upload(ctx context.Context, conversationID, uploaderID uuid.UUID, fileName, declaredType string, body io.ReadSeeker, size int64) (*model.Attachment, error) {
+	if size > s.limits.MaxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	contentType, err := detectContentType(body, declaredType)
+	if err != nil {
+		return nil, err
+	}
+	if !s.allowed(contentType) {
+		return nil, ErrUnsupportedType
+	}
+
+	attachment := &model.Attachment{
+		ID:             uuid.New(),
+		ConversationID: conversationID,
+		UploaderID:     uploaderID,
+		FileName:       cleanFileName(fileName),
+		ContentType:    contentType,
+		Size:           size,
+	}
+	attachment.StorageKey = conversationID.String() + "/" + attachment.ID.String()
+
+	if err := s.store.Put(ctx, attachment.StorageKey, body, size, contentType); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, attachment); err != nil {
+		if delErr := s.store.Delete(ctx, attachment.StorageKey); delErr != nil {
+			log.Printf("Error removing orphaned blob %s: %v", attachment.StorageKey, delErr)
+		}
+		return nil, err
+	}
+
+	return attachment, s.sign(ctx, attachment)
+}
+
+// Get returns an attachment userID can see, with a fresh download URL.
+// Attachments they can't see are reported as not found.
+func (s *AttachmentService) Get(ctx context.Context, id, userID uuid.UUID) (*model.Attachment, error) {
+	attachment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.repo.CanAccess(ctx, attachment, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return attachment, s.sign(ctx, attachment)
+}
+
+// sign fills in the attachment's signed download URL.
+func (s *AttachmentService) sign(ctx context.Context, attachment *model.Attachment) error {
+	expiresAt := time.Now().Add(s.limits.URLTTL)
+	url, err := s.store.SignedURL(ctx, attachment.StorageKey, storage.DownloadOptions{
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Inline:      inlineType(attachment.ContentType),
+	}, s.limits.URLTTL)
+	if err != nil {
+		return err
+	}
+	attachment.URL = url
+	attachment.URLExpiresAt = &expiresAt
+	return nil
+}
+
+func (s *AttachmentService) allowed(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, allowed := range s.limits.AllowedTypes {
+		if allowed == mediaType {
+			return true
+		}
+		if family, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, family+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// detectContentType sniffs body's type and rewinds it. A declared type is
+// only trusted when the data is unrecognizable.
+func detectContentType(body io.ReadSeeker, declared string) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	sniffed := http.DetectContentType(head[:n])
+	if sniffed != "application/octet-stream" || declared == "" {
+		return sniffed, nil
+	}
+	if mediaType, params, err := mime.ParseMediaType(declared); err == nil {
+		return mime.FormatMediaType(mediaType, params), nil
+	}
+	return sniffed, nil
+}
+
+// inlineType reports whether browsers may display the type in place. Anything
+// that could run script is always downloaded.
+func inlineType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "image/") ||
+		strings.HasPrefix(mediaType, "video/") ||
+		strings.HasPrefix(mediaType, "audio/")
+}
+
+// cleanFileName keeps the base name of an uploaded file, as valid UTF-8 of at
+// most 255 bytes.
+func cleanFileName(name string) string {
+	name = strings.ToValidUTF8(filepath.Base(strings.ReplaceAll(name, "\\", "/")), "")
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// attachmentRefs dedupes the attachments a message references and checks
+// their number.
+func attachmentRefs(ids []uuid.UUID) ([]uuid.UUID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	seen := make(map[uuid.UUID]bool, len(ids))
+	refs := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if id == uuid.Nil {
+			return nil, ErrInvalidAttachment
+		}
+		if !seen[id] {
+			seen[id] = true
+			refs = append(refs, id)
+		}
+	}
+	if len(refs) > maxMessageAttachments {
+		return nil, ErrInvalidAttachment
+	}
+	return refs, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// Authorizer decides what a member may do in a conversation, based on their
+// role and the conversation's permission policy. The HTTP handlers and the
+// WebSocket hub ask it before calling into the services, which only enforce
+// invariants that hold for everyone.
+type Authorizer struct {
+	conversations *ConversationService
+}
+
+func NewAuthorizer(conversations *ConversationService) *Authorizer {
+	return &Authorizer{conversations: conversations}
+}
+
+// Authorize returns ErrNotMember if userID does not belong to the
+// conversation and ErrForbidden if their role lacks perm.
+func (a *Authorizer) Authorize(ctx context.Context, conversationID, userID uuid.UUID, perm model.Permission) error {
+	_, err := a.authorize(ctx, conversationID, userID, perm)
+	return err
+}
+
+func (a *Authorizer) authorize(ctx context.Context, conversationID, userID uuid.UUID, perm model.Permission) (*conversationAccess, error) {
+	access, err := a.conversations.access(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	role, ok := access.roles[userID]
+	if !ok {
+		return nil, ErrNotMember
+	}
+
+	required, ok := access.policy[perm]
+	if !ok || model.RoleRank(role) < model.RoleRank(required) {
+		return nil, ErrForbidden
+	}
+	return access, nil
+}
+
+// AuthorizeOver is Authorize for an action on another member, which also
+// requires the actor to outrank them. A target that is not a member yields
+// ErrNotMember.
+func (a *Authorizer) AuthorizeOver(ctx context.Context, conversationID, actorID, targetID uuid.UUID, perm model.Permission) error {
+	access, err := a.authorize(ctx, conversationID, actorID, perm)
+	if err != nil {
+		return err
+	}
+
+	targetRole, ok := access.roles[targetID]
+	if !ok {
+		return ErrNotMember
+	}
+	if model.RoleRank(access.roles[actorID]) <= model.RoleRank(targetRole) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeAdd checks that actorID may add a member with the given role.
+// Members can only be given roles below the actor's own.
+func (a *Authorizer) AuthorizeAdd(ctx context.Context, conversationID, actorID uuid.UUID, role string) error {
+	access, err := a.authorize(ctx, conversationID, actorID, model.PermAddMembers)
+	if err != nil {
+		return err
+	}
+	if model.RoleRank(role) >= model.RoleRank(access.roles[actorID]) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeRoleChange checks that actorID may give targetID the given role:
+// the actor must outrank the target and may only grant roles below their
+// own, except that the owner may hand ownership over.
+func (a *Authorizer) AuthorizeRoleChange(ctx context.Context, conversationID, actorID, targetID uuid.UUID, role string) error {
+	if err := a.AuthorizeOver(ctx, conversationID, actorID, targetID, model.PermChangeRoles); err != nil {
+		return err
+	}
+
+	actorRole, err := a.conversations.MemberRole(ctx, conversationID, actorID)
+	if err != nil {
+		return err
+	}
+	if role == model.RoleOwner && actorRole == model.RoleOwner {
+		return nil
+	}
+	if model.RoleRank(role) >= model.RoleRank(actorRole) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeDelete checks that actorID may delete msg with the given scope.
+// Anyone in the conversation may hide a message for themselves; deleting for
+// everyone is open to the sender, and to roles with PermDeleteMessages for
+// other members' messages. System messages can't be deleted for everyone,
+// not even by the member who caused them.
+func (a *Authorizer) AuthorizeDelete(ctx context.Context, msg *model.Message, actorID uuid.UUID, scope string) error {
+	if scope == model.DeleteScopeEveryone && msg.Type == model.MessageTypeSystem {
+		if err := a.conversations.RequireMember(ctx, msg.ConversationID, actorID); err != nil {
+			return err
+		}
+		return ErrSystemMessage
+	}
+	if scope == model.DeleteScopeEveryone && msg.SenderID != actorID {
+		return a.Authorize(ctx, msg.ConversationID, actorID, model.PermDeleteMessages)
+	}
+	return a.conversations.RequireMember(ctx, msg.ConversationID, actorID)
+}
+
+// Policy returns the conversation's effective permission policy. The caller
+// must be a member.
+func (a *Authorizer) Policy(ctx context.Context, conversationID, userID uuid.UUID) (model.Policy, error) {
+	access, err := a.conversations.access(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := access.roles[userID]; !ok {
+		return nil, ErrNotMember
+	}
+	return access.policy, nil
+}
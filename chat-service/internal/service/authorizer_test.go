@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// testGroup is a group with one member of each role.
+type testGroup struct {
+	id                                      uuid.UUID
+	owner, admin, moderator, member, reader uuid.UUID
+	outsider                                uuid.UUID
+	authorizer                              *Authorizer
+}
+
+func newTestGroup(permissions model.Policy) *testGroup {
+	g := &testGroup{
+		id:        uuid.New(),
+		owner:     uuid.New(),
+		admin:     uuid.New(),
+		moderator: uuid.New(),
+		member:    uuid.New(),
+		reader:    uuid.New(),
+		outsider:  uuid.New(),
+	}
+
+	conv := &model.Conversation{ID: g.id, Type: "group", Permissions: permissions}
+	for userID, role := range map[uuid.UUID]string{
+		g.owner:     model.RoleOwner,
+		g.admin:     model.RoleAdmin,
+		g.moderator: model.RoleModerator,
+		g.member:    model.RoleMember,
+		g.reader:    model.RoleReadonly,
+	} {
+		conv.Members = append(conv.Members, model.ConversationMember{ConversationID: g.id, UserID: userID, Role: role})
+	}
+
+	repo := newFakeConversations()
+	repo.add(conv)
+	g.authorizer = NewAuthorizer(NewConversationService(repo))
+	return g
+}
+
+func wantErr(t *testing.T, what string, got, want error) {
+	t.Helper()
+	if want == nil && got != nil || want != nil && !errors.Is(got, want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGroup(model.Policy{model.PermPin: model.RoleMember})
+
+	tests := []struct {
+		name string
+		user uuid.UUID
+		perm model.Permission
+		want error
+	}{
+		{"member posts", g.member, model.PermPost, nil},
+		{"readonly posts", g.reader, model.PermPost, ErrForbidden},
+		{"moderator deletes messages", g.moderator, model.PermDeleteMessages, nil},
+		{"member deletes messages", g.member, model.PermDeleteMessages, ErrForbidden},
+		{"admin adds members", g.admin, model.PermAddMembers, nil},
+		{"moderator adds members", g.moderator, model.PermAddMembers, ErrForbidden},
+		{"admin deletes conversation", g.admin, model.PermDeleteConversation, ErrForbidden},
+		{"owner deletes conversation", g.owner, model.PermDeleteConversation, nil},
+		{"override lowers pin", g.member, model.PermPin, nil},
+		{"override keeps readonly out", g.reader, model.PermPin, ErrForbidden},
+		{"unknown permission", g.owner, model.Permission("launch"), ErrForbidden},
+		{"outsider", g.outsider, model.PermPost, ErrNotMember},
+	}
+	for _, tt := range tests {
+		err := g.authorizer.Authorize(ctx, g.id, tt.user, tt.perm)
+		wantErr(t, tt.name, err, tt.want)
+	}
+
+	err := g.authorizer.Authorize(ctx, uuid.New(), g.owner, model.PermPost)
+	wantErr(t, "missing conversation", err, ErrNotMember)
+}
+
+func TestAuthorizeOver(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGroup(nil)
+
+	tests := []struct {
+		name          string
+		actor, target uuid.UUID
+		want          error
+	}{
+		{"owner over admin", g.owner, g.admin, nil},
+		{"admin over moderator", g.admin, g.moderator, nil},
+		{"admin over member", g.admin, g.member, nil},
+		{"admin over owner", g.admin, g.owner, ErrForbidden},
+		{"admin over itself", g.admin, g.admin, ErrForbidden},
+		{"moderator lacks permission", g.moderator, g.member, ErrForbidden},
+		{"target not a member", g.owner, g.outsider, ErrNotMember},
+	}
+	for _, tt := range tests {
+		err := g.authorizer.AuthorizeOver(ctx, g.id, tt.actor, tt.target, model.PermRemoveMembers)
+		wantErr(t, tt.name, err, tt.want)
+	}
+}
+
+func TestAuthorizeAdd(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGroup(nil)
+
+	tests := []struct {
+		name  string
+		actor uuid.UUID
+		role  string
+		want  error
+	}{
+		{"admin adds member", g.admin, model.RoleMember, nil},
+		{"admin adds moderator", g.admin, model.RoleModerator, nil},
+		{"admin adds admin", g.admin, model.RoleAdmin, ErrForbidden},
+		{"owner adds admin", g.owner, model.RoleAdmin, nil},
+		{"owner adds owner", g.owner, model.RoleOwner, ErrForbidden},
+		{"moderator adds member", g.moderator, model.RoleMember, ErrForbidden},
+	}
+	for _, tt := range tests {
+		err := g.authorizer.AuthorizeAdd(ctx, g.id, tt.actor, tt.role)
+		wantErr(t, tt.name, err, tt.want)
+	}
+}
+
+func TestAuthorizeRoleChange(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGroup(nil)
+
+	tests := []struct {
+		name          string
+		actor, target uuid.UUID
+		role          string
+		want          error
+	}{
+		{"admin promotes member to moderator", g.admin, g.member, model.RoleModerator, nil},
+		{"admin demotes moderator", g.admin, g.moderator, model.RoleReadonly, nil},
+		{"admin promotes member to admin", g.admin, g.member, model.RoleAdmin, ErrForbidden},
+		{"admin demotes owner", g.admin, g.owner, model.RoleMember, ErrForbidden},
+		{"admin changes itself", g.admin, g.admin, model.RoleMember, ErrForbidden},
+		{"owner transfers ownership", g.owner, g.admin, model.RoleOwner, nil},
+		{"moderator lacks permission", g.moderator, g.member, model.RoleReadonly, ErrForbidden},
+		{"target not a member", g.owner, g.outsider, model.RoleMember, ErrNotMember},
+	}
+	for _, tt := range tests {
+		err := g.authorizer.AuthorizeRoleChange(ctx, g.id, tt.actor, tt.target, tt.role)
+		wantErr(t, tt.name, err, tt.want)
+	}
+}
+
+func TestAuthorizeDelete(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGroup(nil)
+	msg := &model.Message{ConversationID: g.id, SenderID: g.member}
+
+	tests := []struct {
+		name  string
+		actor uuid.UUID
+		scope string
+		want  error
+	}{
+		{"sender for everyone", g.member, model.DeleteScopeEveryone, nil},
+		{"moderator for everyone", g.moderator, model.DeleteScopeEveryone, nil},
+		{"other member for everyone", g.reader, model.DeleteScopeEveryone, ErrForbidden},
+		{"other member for me", g.reader, model.DeleteScopeMe, nil},
+		{"outsider for me", g.outsider, model.DeleteScopeMe, ErrNotMember},
+	}
+	for _, tt := range tests {
+		err := g.authorizer.AuthorizeDelete(ctx, msg, tt.actor, tt.scope)
+		wantErr(t, tt.name, err, tt.want)
+	}
+}
+
+func TestAuthorizeDeleteSystemMessage(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGroup(nil)
+	msg := &model.Message{ConversationID: g.id, SenderID: g.admin, Type: model.MessageTypeSystem}
+
+	tests := []struct {
+		name  string
+		actor uuid.UUID
+		scope string
+		want  error
+	}{
+		{"actor for everyone", g.admin, model.DeleteScopeEveryone, ErrSystemMessage},
+		{"owner for everyone", g.owner, model.DeleteScopeEveryone, ErrSystemMessage},
+		{"actor for me", g.admin, model.DeleteScopeMe, nil},
+		{"outsider for everyone", g.outsider, model.DeleteScopeEveryone, ErrNotMember},
+	}
+	for _, tt := range tests {
+		err := g.authorizer.AuthorizeDelete(ctx, msg, tt.actor, tt.scope)
+		wantErr(t, tt.name, err, tt.want)
+	}
+}
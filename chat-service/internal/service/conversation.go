@@ -2,82 +2,226 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/chatmenow/chat-service/internal/model"
 	"github.com/chatmenow/chat-service/internal/repository"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+var ErrNotMember = errors.New("not a member of this conversation")
+
 type ConversationService struct {
-	repo repository.ConversationRepository
+	repo    repository.ConversationRepository
+	members *membershipCache
 }
 
 func NewConversationService(repo repository.ConversationRepository) *ConversationService {
-	return &ConversationService{repo: repo}
+	return &ConversationService{
+		repo:    repo,
+		members: newMembershipCache(membershipCacheTTL),
+	}
 }
 
-func (s *ConversationService) Create(ctx context.Context, req *model.CreateConversationRequest, createdBy uuid.UUID) (*model.Conversation, error) {
+// Create creates a conversation with the creator and req.MemberIDs as
+// members. There is at most one direct conversation per pair of users: if it
+// already exists it is returned with created set to false.
+func (s *ConversationService) Create(ctx context.Context, req *model.CreateConversationRequest, createdBy uuid.UUID) (conv *model.Conversation, created bool, err error) {
 	// Validate conversation type
 	if req.Type != "direct" && req.Type != "group" {
-		return nil, fmt.Errorf("invalid conversation type: %s", req.Type)
+		return nil, false, fmt.Errorf("invalid conversation type: %s", req.Type)
 	}
 
-	// Add creator to members if not present
-	found := false
+	// Creator first, then the other members without duplicates
+	memberIDs := []uuid.UUID{createdBy}
+	seen := map[uuid.UUID]bool{createdBy: true}
 	for _, id := range req.MemberIDs {
-		if id == createdBy {
-			found = true
-			break
+		if !seen[id] {
+			seen[id] = true
+			memberIDs = append(memberIDs, id)
 		}
 	}
-	if !found {
-		req.MemberIDs = append(req.MemberIDs, createdBy)
-	}
+	req.MemberIDs = memberIDs
 
 	// For direct chat, must have exactly 2 members (after adding creator)
 	if req.Type == "direct" && len(req.MemberIDs) != 2 {
-		return nil, fmt.Errorf("direct conversation must have exactly 2 members (you + 1 other person)")
+		return nil, false, fmt.Errorf("direct conversation must have exactly 2 members (you + 1 other person)")
 	}
 
-	conv := &model.Conversation{
+	conv = &model.Conversation{
 		Name:      req.Name,
 		Type:      req.Type,
 		CreatedBy: createdBy,
 	}
 
+	if req.Type == "direct" {
+		key := model.DirectKey(req.MemberIDs[0], req.MemberIDs[1])
+		existing, err := s.repo.FindByDirectKey(ctx, key)
+		if err == nil {
+			return existing, false, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, err
+		}
+		conv.DirectKey = &key
+	}
+
 	if err := s.repo.Create(ctx, conv, req.MemberIDs); err != nil {
-		return nil, err
+		// Lost a race with a concurrent request for the same pair
+		if errors.Is(err, gorm.ErrDuplicatedKey) && conv.DirectKey != nil {
+			existing, findErr := s.repo.FindByDirectKey(ctx, *conv.DirectKey)
+			if findErr != nil {
+				return nil, false, err
+			}
+			return existing, false, nil
+		}
+		return nil, false, err
 	}
+	s.members.invalidate(conv.ID)
 
-	return conv, nil
+	return conv, true, nil
 }
 
 func (s *ConversationService) GetByID(ctx context.Context, id uuid.UUID) (*model.Conversation, error) {
 	return s.repo.FindByID(ctx, id)
 }
 
-func (s *ConversationService) GetByUser(ctx context.Context, userID uuid.UUID) ([]model.Conversation, error) {
-	return s.repo.FindByUser(ctx, userID)
+// GetByUser lists the user's archived or unarchived conversations, pinned ones first.
+func (s *ConversationService) GetByUser(ctx context.Context, userID uuid.UUID, archived bool) ([]model.ConversationSummary, error) {
+	return s.repo.FindByUser(ctx, userID, archived)
 }
 
 func (s *ConversationService) GetMembers(ctx context.Context, conversationID uuid.UUID) ([]model.ConversationMember, error) {
 	return s.repo.GetMembers(ctx, conversationID)
 }
 
-func (s *ConversationService) AddMember(ctx context.Context, conversationID, userID uuid.UUID, role string) error {
+// access returns the members' roles and the permission policy of a live
+// conversation, or ErrNotMember if it does not exist. Results are served from
+// a short-lived cache.
+func (s *ConversationService) access(ctx context.Context, conversationID uuid.UUID) (*conversationAccess, error) {
+	if access, ok := s.members.get(conversationID); ok {
+		return access, nil
+	}
+
+	conv, err := s.repo.FindByID(ctx, conversationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotMember
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	access := &conversationAccess{
+		roles:  make(map[uuid.UUID]string, len(conv.Members)),
+		policy: conv.EffectivePolicy(),
+	}
+	for _, m := range conv.Members {
+		access.roles[m.UserID] = m.Role
+	}
+	s.members.set(conversationID, access)
+	return access, nil
+}
+
+// MemberRole returns the user's role in the conversation, or ErrNotMember.
+func (s *ConversationService) MemberRole(ctx context.Context, conversationID, userID uuid.UUID) (string, error) {
+	access, err := s.access(ctx, conversationID)
+	if err != nil {
+		return "", err
+	}
+
+	role, ok := access.roles[userID]
+	if !ok {
+		return "", ErrNotMember
+	}
+	return role, nil
+}
+
+// IsMember reports whether userID currently belongs to the conversation.
+func (s *ConversationService) IsMember(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	_, err := s.MemberRole(ctx, conversationID, userID)
+	if errors.Is(err, ErrNotMember) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RequireMember returns ErrNotMember if userID does not belong to the conversation.
+func (s *ConversationService) RequireMember(ctx context.Context, conversationID, userID uuid.UUID) error {
+	_, err := s.MemberRole(ctx, conversationID, userID)
+	return err
+}
+
+// AddMember adds userID with the given role and returns the new member. It
+// returns ErrAlreadyMember if the user already belongs to the conversation.
+func (s *ConversationService) AddMember(ctx context.Context, conversationID, userID uuid.UUID, role string) (*model.ConversationMember, error) {
 	member := &model.ConversationMember{
 		ConversationID: conversationID,
 		UserID:         userID,
 		Role:           role,
 	}
-	return s.repo.AddMember(ctx, member)
+	if err := s.repo.AddMember(ctx, member); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrAlreadyMember
+		}
+		return nil, err
+	}
+	s.members.invalidate(conversationID)
+	return member, nil
 }
 
 func (s *ConversationService) RemoveMember(ctx context.Context, conversationID, userID uuid.UUID) error {
-	return s.repo.RemoveMember(ctx, conversationID, userID)
+	if err := s.repo.RemoveMember(ctx, conversationID, userID); err != nil {
+		return err
+	}
+	s.members.invalidate(conversationID)
+	return nil
 }
 
 func (s *ConversationService) Update(ctx context.Context, conv *model.Conversation) error {
 	return s.repo.Update(ctx, conv)
 }
+
+// MarkRead advances the user's read pointer in the conversation. It returns
+// nil when the pointer was already at or past messageID.
+func (s *ConversationService) MarkRead(ctx context.Context, conversationID, userID, messageID uuid.UUID) (*model.ReadReceipt, error) {
+	receipt := &model.ReadReceipt{
+		ConversationID: conversationID,
+		UserID:         userID,
+		MessageID:      messageID,
+		ReadAt:         time.Now(),
+	}
+
+	advanced, err := s.repo.MarkRead(ctx, conversationID, userID, messageID, receipt.ReadAt)
+	if err != nil {
+		return nil, err
+	}
+	if !advanced {
+		return nil, nil
+	}
+	return receipt, nil
+}
+
+// GetReadReceiptsSince returns the read pointers that moved after since.
+func (s *ConversationService) GetReadReceiptsSince(ctx context.Context, conversationID uuid.UUID, since time.Time) ([]model.ReadReceipt, error) {
+	members, err := s.repo.FindReadSince(ctx, conversationID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := make([]model.ReadReceipt, 0, len(members))
+	for _, m := range members {
+		if m.LastReadMessageID == nil || m.LastReadAt == nil {
+			continue
+		}
+		receipts = append(receipts, model.ReadReceipt{
+			ConversationID: conversationID,
+			UserID:         m.UserID,
+			MessageID:      *m.LastReadMessageID,
+			ReadAt:         *m.LastReadAt,
+		})
+	}
+	return receipts, nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeConversations is an in-memory ConversationRepository for the methods the
+// tests use; any other call panics.
+type fakeConversations struct {
+	repository.ConversationRepository
+
+	conversations map[uuid.UUID]*model.Conversation
+	direct        map[string]*model.Conversation
+	createErr     error
+	created       []*model.Conversation
+	lookups       int
+}
+
+func newFakeConversations() *fakeConversations {
+	return &fakeConversations{
+		conversations: make(map[uuid.UUID]*model.Conversation),
+		direct:        make(map[string]*model.Conversation),
+	}
+}
+
+func (f *fakeConversations) add(conv *model.Conversation) {
+	f.conversations[conv.ID] = conv
+	if conv.DirectKey != nil {
+		f.direct[*conv.DirectKey] = conv
+	}
+}
+
+func (f *fakeConversations) Create(ctx context.Context, conv *model.Conversation, memberIDs []uuid.UUID) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	conv.ID = uuid.New()
+	f.created = append(f.created, conv)
+	f.add(conv)
+	return nil
+}
+
+func (f *fakeConversations) AddMember(ctx context.Context, member *model.ConversationMember) error {
+	conv, ok := f.conversations[member.ConversationID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	member.ID = uuid.New()
+	conv.Members = append(conv.Members, *member)
+	return nil
+}
+
+func (f *fakeConversations) FindByID(ctx context.Context, id uuid.UUID) (*model.Conversation, error) {
+	if conv, ok := f.conversations[id]; ok {
+		return conv, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeConversations) FindByDirectKey(ctx context.Context, key string) (*model.Conversation, error) {
+	f.lookups++
+	if conv, ok := f.direct[key]; ok {
+		return conv, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func directRequest(other uuid.UUID) *model.CreateConversationRequest {
+	return &model.CreateConversationRequest{Type: "direct", MemberIDs: []uuid.UUID{other}}
+}
+
+func TestCreateDirectConversation(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeConversations()
+	s := NewConversationService(repo)
+	alice, bob := uuid.New(), uuid.New()
+
+	conv, created, err := s.Create(ctx, directRequest(bob), alice)
+	if err != nil || !created {
+		t.Fatalf("Create = %v, %v; want created", created, err)
+	}
+	if conv.DirectKey == nil || *conv.DirectKey != model.DirectKey(alice, bob) {
+		t.Errorf("DirectKey = %v, want %s", conv.DirectKey, model.DirectKey(alice, bob))
+	}
+
+	again, created, err := s.Create(ctx, directRequest(alice), bob)
+	if err != nil || created {
+		t.Fatalf("second Create = %v, %v; want existing", created, err)
+	}
+	if again.ID != conv.ID {
+		t.Errorf("second Create returned %s, want %s", again.ID, conv.ID)
+	}
+	if len(repo.created) != 1 {
+		t.Errorf("created %d conversations, want 1", len(repo.created))
+	}
+}
+
+// A concurrent request creates the pair's conversation between the lookup and
+// the insert; the unique index rejects the insert and the winner is returned.
+type racingConversations struct {
+	*fakeConversations
+	winner *model.Conversation
+}
+
+func (r *racingConversations) Create(ctx context.Context, conv *model.Conversation, memberIDs []uuid.UUID) error {
+	r.add(r.winner)
+	return gorm.ErrDuplicatedKey
+}
+
+func TestCreateDirectConversationRace(t *testing.T) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+	key := model.DirectKey(alice, bob)
+	winner := &model.Conversation{ID: uuid.New(), Type: "direct", DirectKey: &key}
+	repo := &racingConversations{fakeConversations: newFakeConversations(), winner: winner}
+	s := NewConversationService(repo)
+
+	conv, created, err := s.Create(ctx, directRequest(bob), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || conv.ID != winner.ID {
+		t.Errorf("Create = %s, created %v; want the existing %s", conv.ID, created, winner.ID)
+	}
+	if repo.lookups != 2 {
+		t.Errorf("looked up the key %d times, want 2", repo.lookups)
+	}
+}
+
+func TestCreateGroupDuplicateKeyIsNotRetried(t *testing.T) {
+	repo := newFakeConversations()
+	repo.createErr = gorm.ErrDuplicatedKey
+	s := NewConversationService(repo)
+
+	req := &model.CreateConversationRequest{Type: "group", Name: "team", MemberIDs: []uuid.UUID{uuid.New()}}
+	if _, _, err := s.Create(context.Background(), req, uuid.New()); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("Create err = %v, want ErrDuplicatedKey", err)
+	}
+	if repo.lookups != 0 {
+		t.Errorf("looked up a direct key %d times for a group", repo.lookups)
+	}
+}
+
+func TestCreateDirectRequiresOneOtherMember(t *testing.T) {
+	s := NewConversationService(newFakeConversations())
+	alice := uuid.New()
+
+	for _, members := range [][]uuid.UUID{nil, {alice}, {uuid.New(), uuid.New()}} {
+		req := &model.CreateConversationRequest{Type: "direct", MemberIDs: members}
+		if _, _, err := s.Create(context.Background(), req, alice); err == nil {
+			t.Errorf("Create(direct, %v) succeeded", members)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFieldTooLong      = errors.New("name, avatarUrl or description is too long")
+	ErrInvalidPermission = errors.New("permissions must map configurable actions to roles")
+)
+
+// UpdateDetails applies the fields present in req and returns the updated
+// conversation. Callers check PermEditInfo first.
+func (s *ConversationService) UpdateDetails(ctx context.Context, conversationID uuid.UUID, req *model.UpdateConversationRequest) (*model.Conversation, error) {
+	conv, err := s.repo.FindByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if len(name) > 255 {
+			return nil, ErrFieldTooLong
+		}
+		updates["name"] = name
+		conv.Name = name
+	}
+	if req.AvatarURL != nil {
+		if len(*req.AvatarURL) > 500 {
+			return nil, ErrFieldTooLong
+		}
+		updates["avatar_url"] = *req.AvatarURL
+		conv.AvatarURL = *req.AvatarURL
+	}
+	if req.Description != nil {
+		if len(*req.Description) > 1000 {
+			return nil, ErrFieldTooLong
+		}
+		updates["description"] = *req.Description
+		conv.Description = *req.Description
+	}
+
+	if len(updates) == 0 {
+		return conv, nil
+	}
+	if err := s.repo.UpdateDetails(ctx, conversationID, updates); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// UpdatePermissions merges overrides into the conversation's permission
+// policy and returns the updated conversation. Callers check
+// PermManagePermissions first.
+func (s *ConversationService) UpdatePermissions(ctx context.Context, conversationID uuid.UUID, overrides model.Policy) (*model.Conversation, error) {
+	for perm, role := range overrides {
+		if !perm.IsConfigurable() || !model.IsValidRole(role) {
+			return nil, ErrInvalidPermission
+		}
+	}
+
+	conv, err := s.repo.FindByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if conv.Permissions == nil {
+		conv.Permissions = make(model.Policy, len(overrides))
+	}
+	for perm, role := range overrides {
+		conv.Permissions[perm] = role
+	}
+
+	if err := s.repo.UpdatePermissions(ctx, conversationID, conv.Permissions); err != nil {
+		return nil, err
+	}
+	s.members.invalidate(conversationID)
+	return conv, nil
+}
+
+// Delete soft-deletes the conversation with its members and messages and
+// returns the members it had. Callers check PermDeleteConversation first.
+func (s *ConversationService) Delete(ctx context.Context, conversationID uuid.UUID) ([]model.ConversationMember, error) {
+	conv, err := s.repo.FindByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Delete(ctx, conversationID); err != nil {
+		return nil, err
+	}
+	s.members.invalidate(conversationID)
+
+	return conv.Members, nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import "unicode/utf8"
+
+// maxReactionsPerUser caps how many different emoji one user can put on a
+// single message.
+const maxReactionsPerUser = 20
+
+// maxEmojiParts caps the components of a ZWJ sequence such as 👨‍👩‍👧‍👦.
+const maxEmojiParts = 10
+
+const (
+	zwj             = 0x200D
+	variationEmoji  = 0xFE0F
+	keycap          = 0x20E3
+	blackFlag       = 0x1F3F4
+	tagCancel       = 0xE007F
+	regionalIndFrom = 0x1F1E6
+	regionalIndTo   = 0x1F1FF
+)
+
+// pictographs approximates the Extended_Pictographic property: the code points
+// an emoji can start with. Regional indicators and skin tone modifiers are
+// left out; they only appear inside flags and after a base emoji.
+var pictographs = [][2]rune{
+	{0x00A9, 0x00A9}, {0x00AE, 0x00AE}, {0x203C, 0x203C}, {0x2049, 0x2049},
+	{0x2122, 0x2122}, {0x2139, 0x2139}, {0x2194, 0x2199}, {0x21A9, 0x21AA},
+	{0x231A, 0x231B}, {0x2328, 0x2328}, {0x23CF, 0x23CF}, {0x23E9, 0x23F3},
+	{0x23F8, 0x23FA}, {0x24C2, 0x24C2}, {0x25AA, 0x25AB}, {0x25B6, 0x25B6},
+	{0x25C0, 0x25C0}, {0x25FB, 0x25FE}, {0x2600, 0x27BF}, {0x2934, 0x2935},
+	{0x2B05, 0x2B07}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55},
+	{0x3030, 0x3030}, {0x303D, 0x303D}, {0x3297, 0x3297}, {0x3299, 0x3299},
+	{0x1F000, 0x1F1E5}, {0x1F200, 0x1F3FA}, {0x1F400, 0x1FAFF},
+}
+
+func isPictograph(r rune) bool {
+	for _, rng := range pictographs {
+		if r < rng[0] {
+			return false
+		}
+		if r <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalIndFrom && r <= regionalIndTo
+}
+
+func isSkinTone(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+func isTag(r rune) bool {
+	return r >= 0xE0020 && r <= 0xE007E
+}
+
+// isEmoji reports whether s is exactly one emoji: a flag, a keycap, or a
+// ZWJ sequence of pictographs each optionally followed by a variation
+// selector, a skin tone or (for subdivision flags) a tag sequence.
+func isEmoji(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return false
+	}
+	runes := []rune(s)
+
+	if isRegionalIndicator(runes[0]) {
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	}
+
+	if r := runes[0]; r == '#' || r == '*' || (r >= '0' && r <= '9') {
+		rest := runes[1:]
+		if len(rest) > 0 && rest[0] == variationEmoji {
+			rest = rest[1:]
+		}
+		return len(rest) == 1 && rest[0] == keycap
+	}
+
+	parts := 0
+	for i := 0; i < len(runes); {
+		if parts == maxEmojiParts || !isPictograph(runes[i]) {
+			return false
+		}
+		base := runes[i]
+		parts++
+		i++
+
+		if i < len(runes) && runes[i] == variationEmoji {
+			i++
+		}
+		if i < len(runes) && isSkinTone(runes[i]) {
+			i++
+		}
+		if base == blackFlag && i < len(runes) && isTag(runes[i]) {
+			for i < len(runes) && isTag(runes[i]) {
+				i++
+			}
+			if i == len(runes) || runes[i] != tagCancel {
+				return false
+			}
+			i++
+		}
+
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != zwj {
+			return false
+		}
+		i++
+		if i == len(runes) {
+			return false
+		}
+	}
+	return false
+}
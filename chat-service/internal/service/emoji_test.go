@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateEmoji(t *testing.T) {
+	valid := []string{
+		"👍",
+		"❤\ufe0f",                // with variation selector
+		"©",                      // text-default pictograph
+		"👍🏽",                     // skin tone
+		"👨\u200d👩\u200d👧\u200d👦", // ZWJ family
+		"🏳\ufe0f\u200d🌈",         // ZWJ with variation selector
+		"🇻🇳",                     // flag
+		"1\ufe0f\u20e3",          // keycap
+		"#\u20e3",                // keycap without variation selector
+		"🏴\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", // subdivision flag
+	}
+	for _, e := range valid {
+		if err := validateEmoji(e); err != nil {
+			t.Errorf("validateEmoji(%q) = %v, want nil", e, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"a",
+		"lol",
+		":thumbsup:",
+		"👍👍",      // two emoji
+		"👍 ",      // trailing space
+		"🇻",       // lone regional indicator
+		"🇻🇳🇻",     // odd regional indicators
+		"👍\u200d", // dangling ZWJ
+		"\u200d👍", // leading ZWJ
+		"🏽",       // lone skin tone
+		"1",
+		"<script>",
+		"\xff",
+		strings.Repeat("👍\u200d", 11) + "👍", // too many parts
+	}
+	for _, e := range invalid {
+		if err := validateEmoji(e); !errors.Is(err, ErrInvalidEmoji) {
+			t.Errorf("validateEmoji(%q) = %v, want ErrInvalidEmoji", e, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxForwardTargets caps how many conversations one forward can reach.
+const maxForwardTargets = 20
+
+var (
+	ErrInvalidForward = errors.New("forward needs between 1 and 20 target conversations")
+	ErrCannotForward  = errors.New("system messages cannot be forwarded")
+)
+
+// ForwardTargets dedupes the requested target conversations and checks their
+// number.
+func ForwardTargets(ids []uuid.UUID) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	targets := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if id == uuid.Nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		targets = append(targets, id)
+	}
+	if len(targets) == 0 || len(targets) > maxForwardTargets {
+		return nil, ErrInvalidForward
+	}
+	return targets, nil
+}
+
+// Forward copies src's content, type, metadata and attachments into each
+// target conversation as a new message from senderID, recording where it came
+// from. Copies never mention anyone. Failures are reported per target. Callers check
+// that senderID can read src and post in every target first.
+func (s *MessageService) Forward(ctx context.Context, src *model.Message, senderID uuid.UUID, targets []uuid.UUID) (*model.ForwardResult, error) {
+	if src.Type == model.MessageTypeSystem {
+		return nil, ErrCannotForward
+	}
+
+	origin, err := s.forwardOrigin(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.ForwardResult{Messages: make([]model.Message, 0, len(targets))}
+	for _, conversationID := range targets {
+		msg := &model.Message{
+			ConversationID: conversationID,
+			SenderID:       senderID,
+			Content:        src.Content,
+			Type:           src.Type,
+			Metadata:       forwardedMetadata(src.Metadata),
+			AttachmentIDs:  src.AttachmentIDs,
+			ForwardedFrom:  origin,
+		}
+		if err := s.repo.Create(ctx, msg, nil); err != nil {
+			// The target may have been deleted since it was authorized
+			reason := "could not forward to this conversation"
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				reason = "conversation not found"
+			} else {
+				log.Printf("Error forwarding message %s to %s: %v", src.ID, conversationID, err)
+			}
+			result.Failed = append(result.Failed, model.ForwardFailure{
+				ConversationID: conversationID,
+				Error:          reason,
+			})
+			continue
+		}
+		result.Messages = append(result.Messages, *msg)
+	}
+	return result, nil
+}
+
+// forwardOrigin returns the provenance to record on copies of src. Forwarding
+// a forward keeps pointing at the original message.
+func (s *MessageService) forwardOrigin(ctx context.Context, src *model.Message) (*model.ForwardedFrom, error) {
+	if src.ForwardedFrom != nil {
+		return src.ForwardedFrom, nil
+	}
+
+	origin := &model.ForwardedFrom{
+		SenderID: src.SenderID,
+		SentAt:   src.CreatedAt,
+	}
+
+	conv, err := s.conversations.GetByID(ctx, src.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.Type != "direct" {
+		conversationID, messageID := src.ConversationID, src.ID
+		origin.ConversationID = &conversationID
+		origin.MessageID = &messageID
+	}
+	return origin, nil
+}
+
+// forwardedMetadata copies metadata without the structured mentions, which
+// refer to members of the original conversation.
+func forwardedMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		if k != "mentions" {
+			copied[k] = v
+		}
+	}
+	return copied
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidInvite     = errors.New("expiresAt must be in the future and maxUses must not be negative")
+	ErrInviteUnavailable = errors.New("invite has expired, been revoked or used up")
+	ErrRequestDecided    = errors.New("join request has already been decided")
+)
+
+// inviteTokenBytes is the entropy of an invite token; it is encoded as 32
+// URL-safe characters.
+const inviteTokenBytes = 24
+
+type InviteService struct {
+	repo          repository.InviteRepository
+	conversations *ConversationService
+}
+
+func NewInviteService(repo repository.InviteRepository, conversations *ConversationService) *InviteService {
+	return &InviteService{
+		repo:          repo,
+		conversations: conversations,
+	}
+}
+
+// Create issues a new invite to a group conversation. Callers check
+// PermAddMembers first.
+func (s *InviteService) Create(ctx context.Context, conversationID, createdBy uuid.UUID, req *model.CreateInviteRequest) (*model.ConversationInvite, error) {
+	if req.MaxUses < 0 || (req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now())) {
+		return nil, ErrInvalidInvite
+	}
+	if err := s.conversations.requireGroup(ctx, conversationID); err != nil {
+		return nil, err
+	}
+
+	token, err := newInviteToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &model.ConversationInvite{
+		ConversationID:   conversationID,
+		Token:            token,
+		CreatedBy:        createdBy,
+		ExpiresAt:        req.ExpiresAt,
+		MaxUses:          req.MaxUses,
+		RequiresApproval: req.RequiresApproval,
+	}
+	if err := s.repo.Create(ctx, invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func newInviteToken() (string, error) {
+	b := make([]byte, inviteTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// List returns the conversation's invites that have not been revoked,
+// including expired and used-up ones.
+func (s *InviteService) List(ctx context.Context, conversationID uuid.UUID) ([]model.ConversationInvite, error) {
+	return s.repo.FindByConversation(ctx, conversationID)
+}
+
+func (s *InviteService) Revoke(ctx context.Context, conversationID, inviteID uuid.UUID) error {
+	return s.repo.Revoke(ctx, conversationID, inviteID)
+}
+
+// Accept joins userID to the invite's conversation as a member, or files a
+// join request if the invite requires approval. Asking again while a request
+// is pending returns that request.
+func (s *InviteService) Accept(ctx context.Context, token string, userID uuid.UUID) (*model.AcceptInviteResponse, error) {
+	invite, err := s.repo.FindByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !invite.Usable(time.Now()) {
+		return nil, ErrInviteUnavailable
+	}
+
+	// The conversation may have been deleted since the invite was issued
+	if err := s.conversations.requireGroup(ctx, invite.ConversationID); err != nil {
+		return nil, err
+	}
+	if ok, err := s.conversations.IsMember(ctx, invite.ConversationID, userID); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, ErrAlreadyMember
+	}
+
+	resp := &model.AcceptInviteResponse{ConversationID: invite.ConversationID}
+
+	// A use only counts if the request or member is stored too, e.g. not
+	// when a concurrent accept by the same user got there first
+	if invite.RequiresApproval {
+		pending, err := s.repo.FindPendingJoinRequest(ctx, invite.ConversationID, userID)
+		if err == nil {
+			resp.JoinRequest = pending
+			return resp, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		req := &model.JoinRequest{
+			ConversationID: invite.ConversationID,
+			UserID:         userID,
+			InviteID:       invite.ID,
+			Status:         model.JoinRequestPending,
+		}
+		if ok, err := s.repo.RequestJoin(ctx, invite.ID, req, time.Now()); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, ErrInviteUnavailable
+		}
+		resp.JoinRequest = req
+		return resp, nil
+	}
+
+	member := &model.ConversationMember{
+		ConversationID: invite.ConversationID,
+		UserID:         userID,
+		Role:           model.RoleMember,
+	}
+	ok, err := s.repo.Join(ctx, invite.ID, member, time.Now())
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrAlreadyMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInviteUnavailable
+	}
+	s.conversations.members.invalidate(invite.ConversationID)
+
+	resp.Member = member
+	return resp, nil
+}
+
+func (s *InviteService) PendingRequests(ctx context.Context, conversationID uuid.UUID) ([]model.JoinRequest, error) {
+	return s.repo.FindPendingJoinRequests(ctx, conversationID)
+}
+
+// Approve accepts a pending join request and adds the user as a member. It
+// returns ErrAlreadyMember if they joined some other way in the meantime.
+// Callers check PermAddMembers first.
+func (s *InviteService) Approve(ctx context.Context, conversationID, requestID, actorID uuid.UUID) (*model.ConversationMember, error) {
+	req, err := s.decide(ctx, conversationID, requestID, actorID, model.JoinRequestApproved)
+	if err != nil {
+		return nil, err
+	}
+	return s.conversations.AddGroupMember(ctx, conversationID, req.UserID, model.RoleMember)
+}
+
+// Reject declines a pending join request. Callers check PermAddMembers first.
+func (s *InviteService) Reject(ctx context.Context, conversationID, requestID, actorID uuid.UUID) (*model.JoinRequest, error) {
+	return s.decide(ctx, conversationID, requestID, actorID, model.JoinRequestRejected)
+}
+
+func (s *InviteService) decide(ctx context.Context, conversationID, requestID, actorID uuid.UUID, status string) (*model.JoinRequest, error) {
+	req, err := s.repo.FindJoinRequest(ctx, conversationID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.repo.DecideJoinRequest(ctx, req, status, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRequestDecided
+	}
+	return req, nil
+}
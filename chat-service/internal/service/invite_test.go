@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeInvites holds join requests in memory; any other call panics.
+type fakeInvites struct {
+	repository.InviteRepository
+	requests map[uuid.UUID]*model.JoinRequest
+}
+
+func (f *fakeInvites) FindJoinRequest(ctx context.Context, conversationID, requestID uuid.UUID) (*model.JoinRequest, error) {
+	req, ok := f.requests[requestID]
+	if !ok || req.ConversationID != conversationID {
+		return nil, errors.New("not found")
+	}
+	return req, nil
+}
+
+func (f *fakeInvites) DecideJoinRequest(ctx context.Context, req *model.JoinRequest, status string, decidedBy uuid.UUID) (bool, error) {
+	if req.Status != model.JoinRequestPending {
+		return false, nil
+	}
+	req.Status = status
+	return true, nil
+}
+
+func TestApprove(t *testing.T) {
+	ctx := context.Background()
+	g := newTestGroup(nil)
+	conversations := g.authorizer.conversations
+	repo := conversations.repo.(*fakeConversations)
+
+	requester := uuid.New()
+	pending := func(userID uuid.UUID) *model.JoinRequest {
+		return &model.JoinRequest{ID: uuid.New(), ConversationID: g.id, UserID: userID, Status: model.JoinRequestPending}
+	}
+	joining, stale := pending(requester), pending(g.member)
+	invites := &fakeInvites{requests: map[uuid.UUID]*model.JoinRequest{joining.ID: joining, stale.ID: stale}}
+	s := NewInviteService(invites, conversations)
+
+	member, err := s.Approve(ctx, g.id, joining.ID, g.admin)
+	if err != nil {
+		t.Fatalf("Approve = %v", err)
+	}
+	if member.UserID != requester || member.Role != model.RoleMember {
+		t.Errorf("Approve added %+v", member)
+	}
+	if ok, _ := conversations.IsMember(ctx, g.id, requester); !ok {
+		t.Error("requester is not a member after Approve")
+	}
+
+	// The requester was added some other way while the request was pending
+	before := len(repo.conversations[g.id].Members)
+	if _, err := s.Approve(ctx, g.id, stale.ID, g.admin); !errors.Is(err, ErrAlreadyMember) {
+		t.Errorf("Approve of a member = %v, want ErrAlreadyMember", err)
+	}
+	if after := len(repo.conversations[g.id].Members); after != before {
+		t.Errorf("Approve of a member added a row: %d members, want %d", after, before)
+	}
+
+	if _, err := s.Approve(ctx, g.id, joining.ID, g.admin); !errors.Is(err, ErrRequestDecided) {
+		t.Errorf("second Approve = %v, want ErrRequestDecided", err)
+	}
+}
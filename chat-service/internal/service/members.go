@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAlreadyMember      = errors.New("user is already a member of this conversation")
+	ErrOwnerMustTransfer  = errors.New("the owner must transfer ownership before leaving")
+	ErrDirectConversation = errors.New("members of a direct conversation cannot be changed")
+	ErrInvalidRole        = errors.New("role must be one of owner, admin, moderator, member or readonly")
+)
+
+// requireGroup returns ErrDirectConversation for direct conversations.
+func (s *ConversationService) requireGroup(ctx context.Context, conversationID uuid.UUID) error {
+	conv, err := s.repo.FindByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	if conv.Type == "direct" {
+		return ErrDirectConversation
+	}
+	return nil
+}
+
+// AddGroupMember adds userID to a group conversation. Ownership can't be
+// given this way. Callers check Authorizer.AuthorizeAdd first.
+func (s *ConversationService) AddGroupMember(ctx context.Context, conversationID, userID uuid.UUID, role string) (*model.ConversationMember, error) {
+	if role == "" {
+		role = model.RoleMember
+	}
+	if !model.IsValidRole(role) || role == model.RoleOwner {
+		return nil, ErrInvalidRole
+	}
+	if err := s.requireGroup(ctx, conversationID); err != nil {
+		return nil, err
+	}
+
+	if ok, err := s.IsMember(ctx, conversationID, userID); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, ErrAlreadyMember
+	}
+
+	return s.AddMember(ctx, conversationID, userID, role)
+}
+
+// RemoveGroupMember removes userID from a group conversation. The owner can
+// only leave once they are the last member. Callers check
+// Authorizer.AuthorizeOver first, unless the member is leaving.
+func (s *ConversationService) RemoveGroupMember(ctx context.Context, conversationID, userID uuid.UUID) error {
+	if err := s.requireGroup(ctx, conversationID); err != nil {
+		return err
+	}
+
+	members, err := s.repo.GetMembers(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, m := range members {
+		if m.UserID != userID {
+			continue
+		}
+		found = true
+		if m.Role == model.RoleOwner && len(members) > 1 {
+			return ErrOwnerMustTransfer
+		}
+	}
+	if !found {
+		return ErrNotMember
+	}
+
+	return s.RemoveMember(ctx, conversationID, userID)
+}
+
+// ChangeRole gives userID a new role and returns the members whose role
+// changed. Granting the owner role transfers ownership: actorID, the current
+// owner, becomes an admin and is returned after the new owner. Callers check
+// Authorizer.AuthorizeRoleChange first.
+func (s *ConversationService) ChangeRole(ctx context.Context, conversationID, actorID, userID uuid.UUID, role string) ([]*model.ConversationMember, error) {
+	if !model.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	var changed []*model.ConversationMember
+	if role == model.RoleOwner {
+		members, err := s.repo.TransferOwnership(ctx, conversationID, actorID, userID)
+		if err != nil {
+			return nil, err
+		}
+		changed = members
+	} else {
+		member, err := s.repo.UpdateMemberRole(ctx, conversationID, userID, role)
+		if err != nil {
+			return nil, err
+		}
+		changed = []*model.ConversationMember{member}
+	}
+	s.members.invalidate(conversationID)
+	return changed, nil
+}
+
+// ForgetMembers drops the cached members and policy of a conversation, e.g.
+// when another replica reports a change.
+func (s *ConversationService) ForgetMembers(conversationID uuid.UUID) {
+	s.members.invalidate(conversationID)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+const membershipCacheTTL = 30 * time.Second
+
+// conversationAccess is what authorization needs to know about a
+// conversation: its members' roles and its effective permission policy.
+type conversationAccess struct {
+	roles  map[uuid.UUID]string
+	policy model.Policy
+}
+
+type membershipEntry struct {
+	access  *conversationAccess
+	expires time.Time
+}
+
+// membershipCache keeps a short-lived copy of each conversation's member roles
+// and permission policy so that hot paths (joining rooms, sending messages)
+// don't hit the database on every frame. Entries are dropped whenever
+// membership changes on this node and expire after membershipCacheTTL to pick
+// up changes made by other replicas.
+type membershipCache struct {
+	mu      sync.RWMutex
+	entries map[uuid.UUID]membershipEntry
+	ttl     time.Duration
+}
+
+func newMembershipCache(ttl time.Duration) *membershipCache {
+	return &membershipCache{
+		entries: make(map[uuid.UUID]membershipEntry),
+		ttl:     ttl,
+	}
+}
+
+func (c *membershipCache) get(conversationID uuid.UUID) (*conversationAccess, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[conversationID]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.access, true
+}
+
+func (c *membershipCache) set(conversationID uuid.UUID, access *conversationAccess) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[conversationID] = membershipEntry{
+		access:  access,
+		expires: time.Now().Add(c.ttl),
+	}
+}
+
+func (c *membershipCache) invalidate(conversationID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, conversationID)
+}
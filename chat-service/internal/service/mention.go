@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+var ErrMentionNotMember = errors.New("mentioned users must be members of the conversation")
+
+// mentionPattern matches @<user id>, @all and @here at the start of the text
+// or after whitespace.
+var mentionPattern = regexp.MustCompile(`(?i)(?:^|\s)@(all|here|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\b`)
+
+// parseMentions collects the mention targets of a message from its text and
+// from an optional structured metadata["mentions"] array, without duplicates.
+func parseMentions(content string, metadata map[string]interface{}) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	add := func(target string) {
+		target = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(target), "@"))
+		if target != "" && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		add(match[1])
+	}
+
+	if list, ok := metadata["mentions"].([]interface{}); ok {
+		for _, item := range list {
+			if target, ok := item.(string); ok {
+				add(target)
+			}
+		}
+	}
+
+	return targets
+}
+
+// resolveMentions parses the mentions of msg, stores the normalized targets in
+// msg.Mentions and returns the users to notify: explicitly mentioned users
+// (who must be members), every member for @all and online members for @here.
+// The sender is never notified of their own message.
+func (s *MessageService) resolveMentions(ctx context.Context, msg *model.Message) ([]uuid.UUID, error) {
+	targets := parseMentions(msg.Content, msg.Metadata)
+	if len(targets) == 0 {
+		msg.Mentions = nil
+		return nil, nil
+	}
+
+	members, err := s.conversations.GetMembers(ctx, msg.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	isMember := make(map[uuid.UUID]bool, len(members))
+	for _, m := range members {
+		isMember[m.UserID] = true
+	}
+
+	var users []uuid.UUID
+	seen := map[uuid.UUID]bool{msg.SenderID: true}
+	notify := func(userID uuid.UUID) {
+		if !seen[userID] {
+			seen[userID] = true
+			users = append(users, userID)
+		}
+	}
+
+	for _, target := range targets {
+		switch target {
+		case model.MentionAll:
+			for _, m := range members {
+				notify(m.UserID)
+			}
+
+		case model.MentionHere:
+			for _, m := range members {
+				if online, err := s.presence.IsOnline(ctx, m.UserID.String()); err == nil && online {
+					notify(m.UserID)
+				}
+			}
+
+		default:
+			userID, err := uuid.Parse(target)
+			if err != nil {
+				return nil, ErrMentionNotMember
+			}
+			if !isMember[userID] {
+				return nil, ErrMentionNotMember
+			}
+			notify(userID)
+		}
+	}
+
+	msg.Mentions = targets
+	return users, nil
+}
+
+// GetMentions returns a page of the messages mentioning userID, newest first.
+func (s *MessageService) GetMentions(ctx context.Context, userID uuid.UUID, before *model.MessageCursor, limit int) (*model.MentionPage, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	messages, hasMore, err := s.repo.FindMentions(ctx, userID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.MentionPage{Messages: messages}
+	if hasMore && len(messages) > 0 {
+		page.NextCursor = model.CursorFor(&messages[len(messages)-1]).Encode()
+	}
+	if page.Messages == nil {
+		page.Messages = []model.Message{}
+	}
+	return page, nil
+}
+
+// GetMentionedUsers returns the users to notify about a message's mentions.
+func (s *MessageService) GetMentionedUsers(ctx context.Context, messageID uuid.UUID) ([]uuid.UUID, error) {
+	return s.repo.FindMentionedUsers(ctx, messageID)
+}
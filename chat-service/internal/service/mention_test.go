@@ -0,0 +1,52 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	const (
+		alice = "0b5f9a3e-6a43-4c52-9d2b-6f1d2f6d1a11"
+		bob   = "7c1e2d4f-1b2a-4e3c-8d5f-9a0b1c2d3e4f"
+	)
+
+	tests := []struct {
+		name     string
+		content  string
+		metadata map[string]interface{}
+		want     []string
+	}{
+		{"none", "hello there", nil, nil},
+		{"user", "hey @" + alice + " look", nil, []string{alice}},
+		{"start of text", "@" + alice, nil, []string{alice}},
+		{"keywords", "@all and @here", nil, []string{"all", "here"}},
+		{"case folded", "@ALL @" + "0B5F9A3E-6A43-4C52-9D2B-6F1D2F6D1A11", nil, []string{"all", alice}},
+		{"deduplicated", "@all @all @" + alice + " @" + alice, nil, []string{"all", alice}},
+		{"email is not a mention", "mail me@all.com", nil, nil},
+		{"word prefix", "@allison", nil, nil},
+		{"truncated id", "@0b5f9a3e-6a43", nil, nil},
+		{
+			"metadata",
+			"hi",
+			map[string]interface{}{"mentions": []interface{}{"@" + bob, " HERE ", 42, ""}},
+			[]string{bob, "here"},
+		},
+		{
+			"text and metadata merged",
+			"@" + alice,
+			map[string]interface{}{"mentions": []interface{}{alice, bob}},
+			[]string{alice, bob},
+		},
+		{"metadata not a list", "", map[string]interface{}{"mentions": alice}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMentions(tt.content, tt.metadata)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMentions(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
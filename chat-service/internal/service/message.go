@@ -2,35 +2,252 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/chatmenow/chat-service/internal/model"
 	"github.com/chatmenow/chat-service/internal/repository"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+var (
+	ErrNotSender          = errors.New("only the sender can modify this message")
+	ErrEditWindowExpired  = errors.New("message can no longer be edited")
+	ErrEmptyContent       = errors.New("message content cannot be empty")
+	ErrForbidden          = errors.New("not allowed to perform this action")
+	ErrSystemMessage      = errors.New("system messages cannot be edited or deleted")
+	ErrInvalidScope       = errors.New("scope must be 'me' or 'everyone'")
+	ErrInvalidClientMsgID = errors.New("clientMsgId must be at most 64 characters")
+	ErrInvalidEmoji       = errors.New("reaction must be a single emoji")
+	ErrTooManyReactions   = errors.New("too many different reactions to this message")
+	ErrInvalidReference   = errors.New("referenced message must exist in the same conversation")
+)
+
+// MissedMessages is what a reconnecting client missed in one conversation.
+type MissedMessages struct {
+	New       []model.Message // seq > lastSeq, tombstones included
+	Changed   []model.Message // seq <= lastSeq, edited or deleted since
+	Since     *time.Time      // reference point used for Changed, nil if unknown
+	Truncated bool            // more than the replay limit is new
+
+	// More than the replay limit changed. Nothing is replayed; the client
+	// has to reload the conversation.
+	ResyncRequired bool
+}
+
 type MessageService struct {
-	repo repository.MessageRepository
+	repo          repository.MessageRepository
+	conversations *ConversationService
+	presence      *PresenceService
+	editWindow    time.Duration
+	maxPins       int // per conversation, 0 = unlimited
 }
 
-func NewMessageService(repo repository.MessageRepository) *MessageService {
-	return &MessageService{repo: repo}
+func NewMessageService(
+	repo repository.MessageRepository,
+	conversations *ConversationService,
+	presence *PresenceService,
+	editWindow time.Duration,
+	maxPins int,
+) *MessageService {
+	return &MessageService{
+		repo:          repo,
+		conversations: conversations,
+		presence:      presence,
+		editWindow:    editWindow,
+		maxPins:       maxPins,
+	}
 }
 
-func (s *MessageService) Create(ctx context.Context, msg *model.Message) error {
+// Create stores msg along with the users it mentions and claims the
+// attachments it references, which must be the sender's own unsent uploads to
+// the conversation. Text messages need content unless they carry attachments.
+// If the sender already sent a message with the same ClientMsgID in this
+// conversation, msg is replaced by the stored message and created is false.
+func (s *MessageService) Create(ctx context.Context, msg *model.Message) (created bool, err error) {
 	if msg.Type == "" {
 		msg.Type = "text"
 	}
-	return s.repo.Create(ctx, msg)
+	attachments, err := attachmentRefs(msg.AttachmentIDs)
+	if err != nil {
+		return false, err
+	}
+	msg.AttachmentIDs = attachments
+	if msg.Type == "text" && strings.TrimSpace(msg.Content) == "" && len(attachments) == 0 {
+		return false, ErrEmptyContent
+	}
+	if msg.ClientMsgID != nil {
+		if *msg.ClientMsgID == "" {
+			msg.ClientMsgID = nil
+		} else if len(*msg.ClientMsgID) > 64 {
+			return false, ErrInvalidClientMsgID
+		}
+	}
+
+	if err := s.resolveReferences(ctx, msg); err != nil {
+		return false, err
+	}
+
+	mentions, err := s.resolveMentions(ctx, msg)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.repo.Create(ctx, msg, mentions)
+	if errors.Is(err, repository.ErrDuplicateMessage) {
+		return false, nil
+	}
+	if errors.Is(err, repository.ErrAttachmentUnavailable) {
+		return false, ErrInvalidAttachment
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PostSystemMessage records a server-generated event, such as a membership
+// change, in the conversation's message stream. actorID is stored as the
+// sender; details are merged into the metadata next to the action.
+func (s *MessageService) PostSystemMessage(ctx context.Context, conversationID, actorID uuid.UUID, action string, details map[string]interface{}) (*model.Message, error) {
+	metadata := map[string]interface{}{"action": action}
+	for k, v := range details {
+		metadata[k] = v
+	}
+
+	msg := &model.Message{
+		ConversationID: conversationID,
+		SenderID:       actorID,
+		Content:        action,
+		Type:           model.MessageTypeSystem,
+		Metadata:       metadata,
+	}
+	if err := s.repo.Create(ctx, msg, nil); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// resolveReferences checks that the message msg replies to and the thread it
+// belongs to are live messages of the same conversation. Replying to a message
+// inside a thread keeps the reply in that thread, and threads never nest: a
+// reply "to" a thread reply joins the root's thread.
+func (s *MessageService) resolveReferences(ctx context.Context, msg *model.Message) error {
+	if msg.ReplyToID != nil {
+		target, err := s.findInConversation(ctx, *msg.ReplyToID, msg.ConversationID)
+		if err != nil {
+			return err
+		}
+		if msg.ThreadRootID == nil && target.ThreadRootID != nil {
+			msg.ThreadRootID = target.ThreadRootID
+		}
+	}
+
+	if msg.ThreadRootID != nil {
+		root, err := s.findInConversation(ctx, *msg.ThreadRootID, msg.ConversationID)
+		if err != nil {
+			return err
+		}
+		if root.ThreadRootID != nil {
+			msg.ThreadRootID = root.ThreadRootID
+		}
+	}
+
+	return nil
+}
+
+func (s *MessageService) findInConversation(ctx context.Context, messageID, conversationID uuid.UUID) (*model.Message, error) {
+	msg, err := s.repo.FindByID(ctx, messageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidReference
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.ConversationID != conversationID {
+		return nil, ErrInvalidReference
+	}
+	return msg, nil
+}
+
+func (s *MessageService) GetMessages(ctx context.Context, conversationID, userID uuid.UUID, query model.MessageQuery) (*model.MessagePage, error) {
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	if query.Limit > 100 {
+		query.Limit = 100
+	}
+	return s.repo.FindByConversation(ctx, conversationID, userID, query)
+}
+
+// GetThread returns a page of the replies in msg's thread, with the same
+// limits as GetMessages. msg may be the root or any reply in the thread.
+func (s *MessageService) GetThread(ctx context.Context, msg *model.Message, userID uuid.UUID, query model.MessageQuery) (*model.ThreadPage, error) {
+	root := msg
+	if msg.ThreadRootID != nil {
+		var err error
+		if root, err = s.repo.FindByID(ctx, *msg.ThreadRootID); err != nil {
+			return nil, err
+		}
+	}
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	if query.Limit > 100 {
+		query.Limit = 100
+	}
+
+	page, err := s.repo.FindThread(ctx, root, userID, query)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ThreadPage{Root: root, MessagePage: *page}, nil
 }
 
-func (s *MessageService) GetMessages(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]model.Message, error) {
-	if limit <= 0 {
-		limit = 50
+// GetThreadParticipants returns the users following root's thread: its sender
+// and everyone who replied.
+func (s *MessageService) GetThreadParticipants(ctx context.Context, root *model.Message) ([]uuid.UUID, error) {
+	return s.repo.FindThreadParticipants(ctx, root)
+}
+
+// GetMissed collects what userID missed in a conversation after lastSeq. since
+// is the server time of the last event the client saw; when nil, the creation
+// time of the message at lastSeq is used instead.
+func (s *MessageService) GetMissed(ctx context.Context, conversationID, userID uuid.UUID, lastSeq int64, since *time.Time, limit int) (*MissedMessages, error) {
+	newMessages, err := s.repo.FindAfterSeq(ctx, conversationID, userID, lastSeq, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	missed := &MissedMessages{New: newMessages, Since: since}
+	if len(newMessages) > limit {
+		missed.New = newMessages[:limit]
+		missed.Truncated = true
+	}
+
+	if missed.Since == nil && lastSeq > 0 {
+		last, err := s.repo.FindBySeq(ctx, conversationID, lastSeq)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if last != nil {
+			missed.Since = &last.CreatedAt
+		}
 	}
-	if limit > 100 {
-		limit = 100
+
+	if missed.Since != nil {
+		missed.Changed, err = s.repo.FindChangedSince(ctx, conversationID, userID, lastSeq, *missed.Since, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(missed.Changed) > limit {
+			return &MissedMessages{ResyncRequired: true}, nil
+		}
 	}
-	return s.repo.FindByConversation(ctx, conversationID, limit, offset)
+
+	return missed, nil
 }
 
 func (s *MessageService) GetByID(ctx context.Context, id uuid.UUID) (*model.Message, error) {
@@ -41,6 +258,101 @@ func (s *MessageService) Update(ctx context.Context, msg *model.Message) error {
 	return s.repo.Update(ctx, msg)
 }
 
-func (s *MessageService) Delete(ctx context.Context, id uuid.UUID) error {
-	return s.repo.Delete(ctx, id)
+// Edit replaces the content of msg on behalf of editorID. Only the sender may
+// edit, and only within the configured edit window (zero disables the limit).
+// System messages are an audit trail and can't be edited by anyone.
+func (s *MessageService) Edit(ctx context.Context, msg *model.Message, editorID uuid.UUID, content string) error {
+	if msg.Type == model.MessageTypeSystem {
+		return ErrSystemMessage
+	}
+	if msg.SenderID != editorID {
+		return ErrNotSender
+	}
+	if s.editWindow > 0 && time.Since(msg.CreatedAt) > s.editWindow {
+		return ErrEditWindowExpired
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return ErrEmptyContent
+	}
+	if content == msg.Content {
+		return nil
+	}
+
+	return s.repo.Edit(ctx, msg, content, editorID)
+}
+
+func (s *MessageService) GetEdits(ctx context.Context, messageID uuid.UUID) ([]model.MessageEdit, error) {
+	return s.repo.FindEdits(ctx, messageID)
+}
+
+func validateEmoji(emoji string) error {
+	if len(emoji) > 64 || !isEmoji(emoji) {
+		return ErrInvalidEmoji
+	}
+	return nil
+}
+
+// React adds (add=true) or removes userID's emoji reaction on msg and returns
+// whether anything changed plus the message's new reaction totals. A user can
+// put at most maxReactionsPerUser different emoji on a message.
+func (s *MessageService) React(ctx context.Context, msg *model.Message, userID uuid.UUID, emoji string, add bool) (bool, []model.ReactionSummary, error) {
+	if err := validateEmoji(emoji); err != nil {
+		return false, nil, err
+	}
+
+	var (
+		changed bool
+		err     error
+	)
+	if add {
+		changed, err = s.repo.AddReaction(ctx, &model.MessageReaction{
+			MessageID: msg.ID,
+			UserID:    userID,
+			Emoji:     emoji,
+		}, maxReactionsPerUser)
+		if errors.Is(err, repository.ErrReactionLimit) {
+			return false, nil, ErrTooManyReactions
+		}
+	} else {
+		changed, err = s.repo.RemoveReaction(ctx, msg.ID, userID, emoji)
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	summaries, err := s.repo.SummarizeReactions(ctx, []uuid.UUID{msg.ID}, uuid.Nil)
+	if err != nil {
+		return false, nil, err
+	}
+
+	reactions := summaries[msg.ID]
+	if reactions == nil {
+		reactions = []model.ReactionSummary{}
+	}
+	return changed, reactions, nil
+}
+
+// Delete removes msg on behalf of actorID. Scope "me" hides it from the actor's
+// history only; "everyone" tombstones it. Callers check
+// Authorizer.AuthorizeDelete first.
+func (s *MessageService) Delete(ctx context.Context, msg *model.Message, actorID uuid.UUID, scope string) error {
+	switch scope {
+	case model.DeleteScopeMe:
+		return s.repo.Hide(ctx, msg.ID, actorID)
+
+	case model.DeleteScopeEveryone:
+		if err := s.repo.Delete(ctx, msg.ID); err != nil {
+			return err
+		}
+		msg.Content = ""
+		msg.Metadata = nil
+		msg.AttachmentIDs = nil
+		msg.Deleted = true
+		return nil
+
+	default:
+		return ErrInvalidScope
+	}
 }
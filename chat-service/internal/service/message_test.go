@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestEditRejectsSystemMessages(t *testing.T) {
+	s := NewMessageService(nil, nil, nil, 15*time.Minute, 0)
+	actor := uuid.New()
+	msg := &model.Message{
+		ID:        uuid.New(),
+		SenderID:  actor,
+		Content:   model.SystemMemberAdded,
+		Type:      model.MessageTypeSystem,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.Edit(context.Background(), msg, actor, "nothing happened"); !errors.Is(err, ErrSystemMessage) {
+		t.Errorf("Edit = %v, want ErrSystemMessage", err)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTooManyPins = errors.New("this conversation already has the maximum number of pinned messages")
+	ErrCannotPin   = errors.New("system messages cannot be pinned")
+)
+
+// findPinnable loads a live message of the conversation. Messages of other
+// conversations are reported as not found. System messages can't be pinned,
+// since each pin posts one of its own.
+func (s *MessageService) findPinnable(ctx context.Context, conversationID, messageID uuid.UUID) (*model.Message, error) {
+	msg, err := s.repo.FindByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.ConversationID != conversationID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if msg.Type == model.MessageTypeSystem {
+		return nil, ErrCannotPin
+	}
+	return msg, nil
+}
+
+// Pin pins a message of the conversation on behalf of actorID. It returns the
+// pin and false if the message was already pinned. Callers check PermPin first.
+func (s *MessageService) Pin(ctx context.Context, conversationID, messageID, actorID uuid.UUID) (*model.PinnedMessage, bool, error) {
+	msg, err := s.findPinnable(ctx, conversationID, messageID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	pin := &model.PinnedMessage{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		PinnedBy:       actorID,
+	}
+	added, err := s.repo.Pin(ctx, pin, s.maxPins)
+	if errors.Is(err, repository.ErrPinLimit) {
+		return nil, false, ErrTooManyPins
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !added {
+		pins, err := s.repo.FindPins(ctx, conversationID)
+		if err != nil {
+			return nil, false, err
+		}
+		for i := range pins {
+			if pins[i].MessageID == messageID {
+				return &pins[i], false, nil
+			}
+		}
+	}
+
+	pin.Message = msg
+	return pin, added, nil
+}
+
+// Unpin removes a message's pin. It returns gorm.ErrRecordNotFound if the
+// message isn't pinned in the conversation. Callers check PermPin first.
+func (s *MessageService) Unpin(ctx context.Context, conversationID, messageID uuid.UUID) error {
+	removed, err := s.repo.Unpin(ctx, conversationID, messageID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetPins returns the conversation's pinned messages, most recently pinned first.
+func (s *MessageService) GetPins(ctx context.Context, conversationID uuid.UUID) ([]model.PinnedMessage, error) {
+	return s.repo.FindPins(ctx, conversationID)
+}
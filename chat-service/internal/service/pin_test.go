@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeMessages serves messages by ID; any other call panics.
+type fakeMessages struct {
+	repository.MessageRepository
+	messages map[uuid.UUID]*model.Message
+}
+
+func (f *fakeMessages) FindByID(ctx context.Context, id uuid.UUID) (*model.Message, error) {
+	if msg, ok := f.messages[id]; ok {
+		return msg, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func TestPinRejectsUnpinnableMessages(t *testing.T) {
+	conversationID := uuid.New()
+	system := &model.Message{ID: uuid.New(), ConversationID: conversationID, Type: model.MessageTypeSystem, Content: model.SystemMessagePinned}
+	elsewhere := &model.Message{ID: uuid.New(), ConversationID: uuid.New(), Type: "text", Content: "hi"}
+	repo := &fakeMessages{messages: map[uuid.UUID]*model.Message{system.ID: system, elsewhere.ID: elsewhere}}
+	s := NewMessageService(repo, nil, nil, 0, 0)
+
+	tests := []struct {
+		name      string
+		messageID uuid.UUID
+		want      error
+	}{
+		{"system message", system.ID, ErrCannotPin},
+		{"other conversation", elsewhere.ID, gorm.ErrRecordNotFound},
+		{"missing", uuid.New(), gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		if _, _, err := s.Pin(context.Background(), conversationID, tt.messageID, uuid.New()); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Pin = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
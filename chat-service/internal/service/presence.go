@@ -14,12 +14,20 @@ func NewPresenceService(redis *repository.RedisClient) *PresenceService {
 	return &PresenceService{redis: redis}
 }
 
-func (s *PresenceService) SetOnline(ctx context.Context, userID string) error {
-	return s.redis.SetUserOnline(ctx, userID)
+// SetOnline records a live session for the user; a user is online while at
+// least one session remains.
+func (s *PresenceService) SetOnline(ctx context.Context, userID, sessionID string) error {
+	return s.redis.SetUserOnline(ctx, userID, sessionID)
 }
 
-func (s *PresenceService) SetOffline(ctx context.Context, userID string) error {
-	return s.redis.SetUserOffline(ctx, userID)
+func (s *PresenceService) SetOffline(ctx context.Context, userID, sessionID string) error {
+	return s.redis.SetUserOffline(ctx, userID, sessionID)
+}
+
+// Refresh keeps the given sessions (user ID -> session IDs) alive. Sessions
+// that are not refreshed within repository.SessionTTL count as offline.
+func (s *PresenceService) Refresh(ctx context.Context, sessions map[string][]string) error {
+	return s.redis.RefreshSessions(ctx, sessions)
 }
 
 func (s *PresenceService) IsOnline(ctx context.Context, userID string) (bool, error) {
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/repository"
+)
+
+var ErrEmptyQuery = errors.New("search query cannot be empty")
+
+type SearchService struct {
+	searcher      repository.MessageSearcher
+	conversations *ConversationService
+}
+
+func NewSearchService(searcher repository.MessageSearcher, conversations *ConversationService) *SearchService {
+	return &SearchService{
+		searcher:      searcher,
+		conversations: conversations,
+	}
+}
+
+// Search returns a page of the messages query.UserID can see that match
+// query. Restricting the search to a conversation requires membership.
+func (s *SearchService) Search(ctx context.Context, query model.SearchQuery) (*model.SearchPage, error) {
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return nil, ErrEmptyQuery
+	}
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	if query.Limit > 100 {
+		query.Limit = 100
+	}
+
+	if query.ConversationID != nil {
+		if err := s.conversations.RequireMember(ctx, *query.ConversationID, query.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	results, hasMore, err := s.searcher.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.SearchPage{Results: results}
+	if hasMore && len(results) > 0 {
+		page.NextCursor = model.CursorFor(&results[len(results)-1].Message).Encode()
+	}
+	return page, nil
+}
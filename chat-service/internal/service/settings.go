@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidSettings = errors.New("notificationLevel must be all, mentions or none, nickname at most 100 characters and mutedUntil in the future")
+
+// GetSettings returns userID's settings for a conversation they belong to.
+func (s *ConversationService) GetSettings(ctx context.Context, conversationID, userID uuid.UUID) (*model.ConversationSettings, error) {
+	if err := s.RequireMember(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	settings, err := s.repo.FindSettings(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = model.DefaultSettings(conversationID, userID)
+	}
+	return settings, nil
+}
+
+// UpdateSettings applies the fields present in req to userID's settings and
+// returns the result.
+func (s *ConversationService) UpdateSettings(ctx context.Context, conversationID, userID uuid.UUID, req *model.UpdateSettingsRequest) (*model.ConversationSettings, error) {
+	now := time.Now()
+	if req.MutedUntil != nil && !req.MutedUntil.After(now) {
+		return nil, ErrInvalidSettings
+	}
+	if req.NotificationLevel != nil {
+		switch *req.NotificationLevel {
+		case model.NotifyAll, model.NotifyMentions, model.NotifyNone:
+		default:
+			return nil, ErrInvalidSettings
+		}
+	}
+
+	settings, err := s.GetSettings(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.Muted != nil && !*req.Muted:
+		settings.Muted = false
+		settings.MutedUntil = nil
+	case req.MutedUntil != nil:
+		settings.Muted = true
+		settings.MutedUntil = req.MutedUntil
+	case req.Muted != nil:
+		settings.Muted = true
+		settings.MutedUntil = nil
+	}
+	if req.Pinned != nil {
+		settings.PinnedAt = toggledAt(settings.PinnedAt, *req.Pinned, now)
+	}
+	if req.Archived != nil {
+		settings.ArchivedAt = toggledAt(settings.ArchivedAt, *req.Archived, now)
+	}
+	if req.NotificationLevel != nil {
+		settings.NotificationLevel = *req.NotificationLevel
+	}
+	if req.Nickname != nil {
+		nickname := strings.TrimSpace(*req.Nickname)
+		if len(nickname) > 100 {
+			return nil, ErrInvalidSettings
+		}
+		settings.Nickname = nickname
+	}
+
+	if err := s.repo.SaveSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// toggledAt keeps the time a flag was set, sets it to now when turning the
+// flag on and clears it when turning it off.
+func toggledAt(at *time.Time, on bool, now time.Time) *time.Time {
+	if !on {
+		return nil
+	}
+	if at != nil {
+		return at
+	}
+	return &now
+}
+
+// Notifiable returns the users among userIDs whose settings allow a direct
+// notification about activity in the conversation: a mention if mention is
+// true, otherwise something like a thread reply.
+func (s *ConversationService) Notifiable(ctx context.Context, conversationID uuid.UUID, userIDs []uuid.UUID, mention bool) ([]uuid.UUID, error) {
+	stored, err := s.repo.FindMembersSettings(ctx, conversationID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[uuid.UUID]*model.ConversationSettings, len(stored))
+	for i := range stored {
+		byUser[stored[i].UserID] = &stored[i]
+	}
+
+	now := time.Now()
+	notifiable := make([]uuid.UUID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if settings, ok := byUser[userID]; ok && !settings.Notifies(now, mention) {
+			continue
+		}
+		notifiable = append(notifiable, userID)
+	}
+	return notifiable, nil
+}
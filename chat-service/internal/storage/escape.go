@@ -0,0 +1,31 @@
+package storage
+
+import "strings"
+
+// encodeRFC3986 percent-encodes everything but the unreserved characters, as
+// required by SigV4 and RFC 5987 filenames.
+func encodeRFC3986(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+// escapePath encodes each segment of a slash-separated key.
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = encodeRFC3986(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLocalStore serves a LocalStore under /files/ of a test server.
+func newTestLocalStore(t *testing.T) (*LocalStore, string) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	dir := filepath.Join(t.TempDir(), "blobs")
+	store, err := NewLocalStore(dir, srv.URL+"/files", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle("/files/", http.StripPrefix("/files", store))
+	return store, dir
+}
+
+func putString(t *testing.T, s BlobStore, key, content, contentType string) {
+	t.Helper()
+	if err := s.Put(context.Background(), key, strings.NewReader(content), int64(len(content)), contentType); err != nil {
+		t.Fatalf("Put(%q) = %v", key, err)
+	}
+}
+
+func signedURL(t *testing.T, s BlobStore, key string, opts DownloadOptions, ttl time.Duration) string {
+	t.Helper()
+	u, err := s.SignedURL(context.Background(), key, opts, ttl)
+	if err != nil {
+		t.Fatalf("SignedURL(%q) = %v", key, err)
+	}
+	return u
+}
+
+func get(t *testing.T, rawURL string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	putString(t, store, "conv/1/report final.txt", "hello", "text/plain")
+
+	u := signedURL(t, store, "conv/1/report final.txt", DownloadOptions{FileName: "report final.txt", ContentType: "text/plain"}, time.Minute)
+	resp, body := get(t, u)
+	if resp.StatusCode != http.StatusOK || body != "hello" {
+		t.Fatalf("GET = %d %q, want 200 \"hello\"", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got, want := resp.Header.Get("Content-Disposition"), "attachment; filename*=UTF-8''report%20final.txt"; got != want {
+		t.Errorf("Content-Disposition = %q, want %q", got, want)
+	}
+
+	if err := store.Delete(context.Background(), "conv/1/report final.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := get(t, u); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after Delete = %d, want 404", resp.StatusCode)
+	}
+	if err := store.Delete(context.Background(), "conv/1/report final.txt"); err != nil {
+		t.Errorf("second Delete = %v, want nil", err)
+	}
+}
+
+func TestLocalStoreRejectsTamperedURLs(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	putString(t, store, "conv/1/a.html", "<script>", "text/html")
+	putString(t, store, "conv/1/b.txt", "other", "text/plain")
+
+	u, err := url.Parse(signedURL(t, store, "conv/1/a.html", DownloadOptions{ContentType: "text/plain"}, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tamper := func(name string, change func(u *url.URL, q url.Values)) {
+		t.Helper()
+		v := *u
+		q := v.Query()
+		change(&v, q)
+		v.RawQuery = q.Encode()
+		if resp, _ := get(t, v.String()); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: GET = %d, want 403", name, resp.StatusCode)
+		}
+	}
+
+	tamper("signature", func(u *url.URL, q url.Values) {
+		sig := []byte(q.Get("signature"))
+		sig[0] ^= 1
+		q.Set("signature", string(sig))
+	})
+	tamper("content type", func(u *url.URL, q url.Values) { q.Set("type", "text/html") })
+	tamper("disposition", func(u *url.URL, q url.Values) { q.Set("disposition", "inline") })
+	tamper("expiry", func(u *url.URL, q url.Values) { q.Set("expires", "99999999999") })
+	tamper("key", func(u *url.URL, q url.Values) { u.Path = strings.Replace(u.Path, "a.html", "b.txt", 1) })
+	tamper("missing signature", func(u *url.URL, q url.Values) { q.Del("signature") })
+}
+
+func TestLocalStoreRejectsExpiredURLs(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	putString(t, store, "conv/1/a.txt", "hello", "text/plain")
+
+	u := signedURL(t, store, "conv/1/a.txt", DownloadOptions{}, -time.Minute)
+	if resp, _ := get(t, u); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expired GET = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, dir := newTestLocalStore(t)
+
+	for _, key := range []string{"", "../escape", "conv/../../escape", "/abs", "conv//a", "conv/./a", "conv/"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if err := store.Delete(context.Background(), key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape")); !os.IsNotExist(err) {
+		t.Errorf("a blob was written outside the store: %v", err)
+	}
+
+	// A validly signed URL for an escaping key still can't reach the file
+	secret := filepath.Join(filepath.Dir(dir), "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	q := url.Values{}
+	expires := "99999999999"
+	q.Set("expires", expires)
+	q.Set("signature", store.sign("../secret.txt", expires, "", ""))
+	store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/../secret.txt?"+q.Encode(), nil))
+	if rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("GET ../secret.txt = %d %q, want 404", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNewLocalStoreRequiresKey(t *testing.T) {
+	if _, err := NewLocalStore(t.TempDir(), "http://localhost/files/", ""); err == nil {
+		t.Error("NewLocalStore accepted an empty signing key")
+	}
+}
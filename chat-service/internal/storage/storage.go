@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files. Keys are slash-separated paths chosen by the
+// caller. Downloads go through short-lived signed URLs so that clients never
+// need credentials for the store itself.
+type BlobStore interface {
+	// Put stores body under key. size is the exact length of body.
+	Put(ctx context.Context, key string, body io.ReadSeeker, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads key until ttl elapses.
+	SignedURL(ctx context.Context, key string, opts DownloadOptions, ttl time.Duration) (string, error)
+}
+
+// DownloadOptions are the response headers a signed URL should produce.
+type DownloadOptions struct {
+	FileName    string
+	ContentType string
+	Inline      bool // display in the browser instead of saving
+}
+
+// contentDisposition formats the Content-Disposition header for opts.
+func (o DownloadOptions) contentDisposition() string {
+	disposition := "attachment"
+	if o.Inline {
+		disposition = "inline"
+	}
+	if o.FileName == "" {
+		return disposition
+	}
+	return disposition + "; filename*=UTF-8''" + encodeRFC3986(o.FileName)
+}
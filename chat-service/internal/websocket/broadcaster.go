@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/chatmenow/chat-service/internal/repository"
+)
+
+const conversationChannelPrefix = "chat:conversation:"
+
+// Broadcaster fans BroadcastMessage envelopes out to every hub instance.
+// Publish may be called from any goroutine; Messages delivers the envelopes
+// this instance should hand to its locally connected clients.
+type Broadcaster interface {
+	Publish(ctx context.Context, msg *BroadcastMessage) error
+	Messages() <-chan *BroadcastMessage
+	Close() error
+}
+
+// MemoryBroadcaster loops messages back to the local hub. It is enough for a
+// single chat-service instance and for tests.
+type MemoryBroadcaster struct {
+	messages chan *BroadcastMessage
+}
+
+func NewMemoryBroadcaster() *MemoryBroadcaster {
+	return &MemoryBroadcaster{messages: make(chan *BroadcastMessage, 256)}
+}
+
+func (b *MemoryBroadcaster) Publish(ctx context.Context, msg *BroadcastMessage) error {
+	select {
+	case b.messages <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *MemoryBroadcaster) Messages() <-chan *BroadcastMessage {
+	return b.messages
+}
+
+func (b *MemoryBroadcaster) Close() error {
+	return nil
+}
+
+// RedisBroadcaster publishes each envelope to a per-conversation Redis channel
+// and pattern-subscribes to all of them, so every replica sees every message
+// and delivers it to whichever of its clients have joined the room.
+type RedisBroadcaster struct {
+	redis    *repository.RedisClient
+	messages chan *BroadcastMessage
+	cancel   context.CancelFunc
+}
+
+func NewRedisBroadcaster(redis *repository.RedisClient) *RedisBroadcaster {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &RedisBroadcaster{
+		redis:    redis,
+		messages: make(chan *BroadcastMessage, 256),
+		cancel:   cancel,
+	}
+	go b.listen(ctx)
+	return b
+}
+
+func (b *RedisBroadcaster) Publish(ctx context.Context, msg *BroadcastMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.redis.Publish(ctx, conversationChannelPrefix+msg.ConversationID, data)
+}
+
+func (b *RedisBroadcaster) Messages() <-chan *BroadcastMessage {
+	return b.messages
+}
+
+func (b *RedisBroadcaster) Close() error {
+	b.cancel()
+	return nil
+}
+
+func (b *RedisBroadcaster) listen(ctx context.Context) {
+	pubsub := b.redis.PSubscribe(ctx, conversationChannelPrefix+"*")
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var msg BroadcastMessage
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				log.Printf("Error decoding broadcast from %s: %v", m.Channel, err)
+				continue
+			}
+			if msg.ConversationID == "" {
+				msg.ConversationID = strings.TrimPrefix(m.Channel, conversationChannelPrefix)
+			}
+
+			select {
+			case b.messages <- &msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -15,18 +16,29 @@ const (
 )
 
 type Client struct {
-	Hub    *Hub
-	Conn   *websocket.Conn
-	Send   chan []byte
-	UserID string
+	Hub       *Hub
+	Conn      *websocket.Conn
+	Send      chan []byte
+	UserID    string
+	DeviceID  string // client-supplied device identifier, stable across reconnects
+	SessionID string // unique per connection
+	limiter   *rateLimiter
 }
 
-func NewClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, userID, deviceID string) *Client {
+	sessionID := uuid.NewString()
+	if deviceID == "" {
+		deviceID = sessionID
+	}
+
 	return &Client{
-		Hub:    hub,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		UserID: userID,
+		Hub:       hub,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		UserID:    userID,
+		DeviceID:  deviceID,
+		SessionID: sessionID,
+		limiter:   newRateLimiter(clientFrameRate, clientFrameBurst),
 	}
 }
 
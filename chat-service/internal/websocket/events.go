@@ -0,0 +1,186 @@
+package websocket
+
+import (
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// NewMessageEvent builds the frame broadcast when a message is created.
+func NewMessageEvent(msg *model.Message) *model.WSEvent {
+	return model.NewWSEvent(model.WSNewMessage, msg)
+}
+
+// MessageUpdatedEvent builds the frame broadcast when a message is edited.
+func MessageUpdatedEvent(msg *model.Message) *model.WSEvent {
+	return model.NewWSEvent(model.WSMessageUpdated, msg)
+}
+
+// ThreadReplyEvent builds the frame sent to thread participants when a reply
+// is posted in the thread.
+func ThreadReplyEvent(msg *model.Message) *model.WSEvent {
+	return model.NewWSEvent(model.WSThreadReply, msg)
+}
+
+// MentionedEvent builds the frame sent directly to a user mentioned in msg.
+func MentionedEvent(msg *model.Message) *model.WSEvent {
+	return model.NewWSEvent(model.WSMentioned, msg)
+}
+
+// ThreadUpdatedEvent builds the frame broadcast to the room when a thread's
+// reply count changes.
+func ThreadUpdatedEvent(root *model.Message) *model.WSEvent {
+	return model.NewWSEvent(model.WSThreadUpdated, &model.ThreadUpdatedPayload{
+		ConversationID: root.ConversationID,
+		RootID:         root.ID,
+		ReplyCount:     root.ThreadReplyCount,
+		LastReplyAt:    root.ThreadLastReplyAt,
+	})
+}
+
+// MessageDeletedEvent builds the frame broadcast when a message is deleted for
+// everyone. deletedBy may be uuid.Nil when replaying from storage.
+func MessageDeletedEvent(msg *model.Message, deletedBy uuid.UUID) *model.WSEvent {
+	payload := &model.MessageDeletedPayload{
+		ID:             msg.ID,
+		ConversationID: msg.ConversationID,
+		Seq:            msg.Seq,
+	}
+	if deletedBy != uuid.Nil {
+		payload.DeletedBy = &deletedBy
+	}
+	return model.NewWSEvent(model.WSMessageDeleted, payload)
+}
+
+// MessageAckEvent tells the sender a message was stored. duplicate is true when
+// the send was a retry of a message stored earlier.
+func MessageAckEvent(msg *model.Message, duplicate bool) *model.WSEvent {
+	return model.NewWSEvent(model.WSMessageAck, &model.MessageAckPayload{
+		ClientMsgID:    msg.ClientMsgID,
+		ID:             msg.ID,
+		ConversationID: msg.ConversationID,
+		Seq:            msg.Seq,
+		CreatedAt:      msg.CreatedAt,
+		Duplicate:      duplicate,
+	})
+}
+
+// MessageNackEvent tells the sender a message was rejected.
+func MessageNackEvent(clientMsgID, code, message string) *model.WSEvent {
+	return model.NewWSEvent(model.WSMessageNack, &model.MessageNackPayload{
+		ClientMsgID: clientMsgID,
+		Code:        code,
+		Message:     message,
+	})
+}
+
+// ReadReceiptEvent builds the frame broadcast when a member reads up to a message.
+func ReadReceiptEvent(receipt *model.ReadReceipt) *model.WSEvent {
+	return model.NewWSEvent(model.WSReadReceipt, receipt)
+}
+
+// ReactionUpdatedEvent builds the frame broadcast when a user adds or removes a
+// reaction. reactions are the message's totals after the change.
+func ReactionUpdatedEvent(msg *model.Message, userID uuid.UUID, emoji string, added bool, reactions []model.ReactionSummary) *model.WSEvent {
+	return model.NewWSEvent(model.WSReactionUpdated, &model.ReactionUpdatedPayload{
+		MessageID:      msg.ID,
+		ConversationID: msg.ConversationID,
+		UserID:         userID,
+		Emoji:          emoji,
+		Added:          added,
+		Reactions:      reactions,
+	})
+}
+
+// MemberAddedEvent builds the frame sent when actorID adds a member.
+func MemberAddedEvent(member *model.ConversationMember, actorID uuid.UUID) *model.WSEvent {
+	return model.NewWSEvent(model.WSMemberAdded, &model.MemberPayload{
+		ConversationID: member.ConversationID,
+		Member:         member,
+		ActorID:        actorID,
+	})
+}
+
+// MemberUpdatedEvent builds the frame sent when actorID changes a member's role.
+func MemberUpdatedEvent(member *model.ConversationMember, actorID uuid.UUID) *model.WSEvent {
+	return model.NewWSEvent(model.WSMemberUpdated, &model.MemberPayload{
+		ConversationID: member.ConversationID,
+		Member:         member,
+		ActorID:        actorID,
+	})
+}
+
+// MemberRemovedEvent builds the frame sent when a member leaves or is removed.
+func MemberRemovedEvent(conversationID, userID, actorID uuid.UUID) *model.WSEvent {
+	return model.NewWSEvent(model.WSMemberRemoved, &model.MemberRemovedPayload{
+		ConversationID: conversationID,
+		UserID:         userID,
+		ActorID:        actorID,
+	})
+}
+
+// ConversationUpdatedEvent builds the frame sent when actorID changes the
+// conversation's name, avatar or description.
+func ConversationUpdatedEvent(conv *model.Conversation, actorID uuid.UUID) *model.WSEvent {
+	details := *conv
+	details.Members = nil
+	return model.NewWSEvent(model.WSConversationUpdated, &model.ConversationUpdatedPayload{
+		Conversation: &details,
+		ActorID:      actorID,
+	})
+}
+
+// ConversationDeletedEvent builds the frame sent when actorID deletes the conversation.
+func ConversationDeletedEvent(conversationID, actorID uuid.UUID) *model.WSEvent {
+	return model.NewWSEvent(model.WSConversationDeleted, &model.ConversationDeletedPayload{
+		ConversationID: conversationID,
+		ActorID:        actorID,
+	})
+}
+
+// MessagePinnedEvent builds the frame broadcast when a message is pinned.
+func MessagePinnedEvent(pin *model.PinnedMessage) *model.WSEvent {
+	return model.NewWSEvent(model.WSMessagePinned, pin)
+}
+
+// MessageUnpinnedEvent builds the frame broadcast when actorID unpins a message.
+func MessageUnpinnedEvent(conversationID, messageID, actorID uuid.UUID) *model.WSEvent {
+	return model.NewWSEvent(model.WSMessageUnpinned, &model.MessageUnpinnedPayload{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		ActorID:        actorID,
+	})
+}
+
+// SettingsUpdatedEvent builds the frame sent to a user's own connections when
+// they change their settings for a conversation.
+func SettingsUpdatedEvent(settings *model.ConversationSettings) *model.WSEvent {
+	return model.NewWSEvent(model.WSSettingsUpdated, settings)
+}
+
+func TypingEvent(payload *model.TypingPayload) *model.WSEvent {
+	return model.NewWSEvent(model.WSUserTyping, payload)
+}
+
+func ResumedEvent(conversationID uuid.UUID, truncated bool) *model.WSEvent {
+	return model.NewWSEvent(model.WSResumed, &model.ResumedPayload{
+		ConversationID: conversationID,
+		Truncated:      truncated,
+	})
+}
+
+// ResyncRequiredEvent tells a resuming client to reload conversations from the
+// REST API because replaying them would be too large.
+func ResyncRequiredEvent(conversationIDs []uuid.UUID) *model.WSEvent {
+	return model.NewWSEvent(model.WSResyncRequired, &model.ResyncRequiredPayload{
+		ConversationIDs: conversationIDs,
+	})
+}
+
+// ErrorEvent builds an error frame. conversationID may be uuid.Nil.
+func ErrorEvent(code, message string, conversationID uuid.UUID) *model.WSEvent {
+	payload := &model.ErrorPayload{Code: code, Message: message}
+	if conversationID != uuid.Nil {
+		payload.ConversationID = &conversationID
+	}
+	return model.NewWSEvent(model.WSError, payload)
+}
@@ -0,0 +1,356 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/service"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// HandleClientMessage decodes one client frame and dispatches it by type.
+// Every failure is reported back to the client as an error frame (or a
+// message_nack for sends) carrying the frame's request ID.
+func (h *Hub) HandleClientMessage(client *Client, messageData []byte) {
+	if !client.limiter.allow() {
+		h.sendError(client, nil, model.ErrCodeRateLimited, "Too many frames, slow down", uuid.Nil)
+		return
+	}
+
+	var req model.WSMessage
+	if err := json.Unmarshal(messageData, &req); err != nil {
+		h.sendError(client, nil, model.ErrCodeInvalidPayload, "Malformed frame", uuid.Nil)
+		return
+	}
+
+	if req.Version != 0 && req.Version != model.ProtocolVersion {
+		h.sendError(client, &req, model.ErrCodeUnsupportedVersion, "Unsupported protocol version", uuid.Nil)
+		return
+	}
+
+	ctx := context.Background()
+
+	switch req.Type {
+	case model.WSJoinConversation:
+		h.handleJoin(ctx, client, &req)
+	case model.WSLeaveConversation:
+		h.handleLeave(client, &req)
+	case model.WSSendMessage:
+		h.handleSendMessage(ctx, client, &req)
+	case model.WSEditMessage:
+		h.handleEditMessage(ctx, client, &req)
+	case model.WSDeleteMessage:
+		h.handleDeleteMessage(ctx, client, &req)
+	case model.WSMarkRead:
+		h.handleMarkRead(ctx, client, &req)
+	case model.WSAddReaction:
+		h.handleReaction(ctx, client, &req, true)
+	case model.WSRemoveReaction:
+		h.handleReaction(ctx, client, &req, false)
+	case model.WSResume:
+		h.handleResume(ctx, client, &req)
+	case model.WSTyping:
+		h.handleTyping(ctx, client, &req)
+	default:
+		h.sendError(client, &req, model.ErrCodeUnknownType, "Unknown frame type: "+req.Type, uuid.Nil)
+	}
+}
+
+func (h *Hub) handleJoin(ctx context.Context, client *Client, req *model.WSMessage) {
+	var p model.JoinRoomPayload
+	if !h.decode(client, req, &p) {
+		return
+	}
+	if !h.authorize(ctx, client, req, p.ConversationID) {
+		return
+	}
+	h.JoinConversation(client, p.ConversationID.String())
+}
+
+func (h *Hub) handleLeave(client *Client, req *model.WSMessage) {
+	var p model.JoinRoomPayload
+	if !h.decode(client, req, &p) {
+		return
+	}
+	h.LeaveConversation(client, p.ConversationID.String())
+}
+
+func (h *Hub) handleSendMessage(ctx context.Context, client *Client, req *model.WSMessage) {
+	var p model.SendMessagePayload
+	if err := json.Unmarshal(req.Payload, &p); err != nil {
+		h.reply(client, req, MessageNackEvent("", model.ErrCodeInvalidPayload, "Invalid payload"))
+		return
+	}
+
+	if p.Type == "" {
+		p.Type = "text"
+	}
+	if !model.IsUserMessageType(p.Type) {
+		h.reply(client, req, MessageNackEvent(p.ClientMsgID, model.ErrCodeInvalidPayload, "Invalid message type"))
+		return
+	}
+
+	if code, reason := h.checkPermission(ctx, client, p.ConversationID, model.PermPost); code != "" {
+		h.reply(client, req, MessageNackEvent(p.ClientMsgID, code, reason))
+		return
+	}
+
+	senderID, _ := uuid.Parse(client.UserID)
+	msg := &model.Message{
+		ConversationID: p.ConversationID,
+		SenderID:       senderID,
+		Content:        p.Content,
+		Type:           p.Type,
+		Metadata:       p.Metadata,
+		ReplyToID:      p.ReplyToID,
+		ThreadRootID:   p.ThreadRootID,
+		AttachmentIDs:  p.AttachmentIDs,
+	}
+	if p.ClientMsgID != "" {
+		msg.ClientMsgID = &p.ClientMsgID
+	}
+
+	created, err := h.messageService.Create(ctx, msg)
+	if err != nil {
+		code, reason := errorCode(err)
+		h.reply(client, req, MessageNackEvent(p.ClientMsgID, code, reason))
+		return
+	}
+
+	h.reply(client, req, MessageAckEvent(msg, !created))
+
+	// A retried send was already broadcast the first time
+	if created {
+		h.PublishMessage(ctx, msg)
+	}
+}
+
+func (h *Hub) handleEditMessage(ctx context.Context, client *Client, req *model.WSMessage) {
+	var p model.EditMessagePayload
+	if !h.decode(client, req, &p) {
+		return
+	}
+
+	msg, err := h.messageService.GetByID(ctx, p.MessageID)
+	if err != nil {
+		h.sendServiceError(client, req, err)
+		return
+	}
+
+	if !h.permit(ctx, client, req, msg.ConversationID, model.PermPost) {
+		return
+	}
+
+	editorID, _ := uuid.Parse(client.UserID)
+	if err := h.messageService.Edit(ctx, msg, editorID, p.Content); err != nil {
+		h.sendServiceError(client, req, err)
+		return
+	}
+
+	h.BroadcastToConversation(msg.ConversationID.String(), MessageUpdatedEvent(msg), nil)
+}
+
+func (h *Hub) handleDeleteMessage(ctx context.Context, client *Client, req *model.WSMessage) {
+	var p model.DeleteMessagePayload
+	if !h.decode(client, req, &p) {
+		return
+	}
+	if p.Scope == "" {
+		p.Scope = model.DeleteScopeMe
+	}
+
+	msg, err := h.messageService.GetByID(ctx, p.MessageID)
+	if err != nil {
+		h.sendServiceError(client, req, err)
+		return
+	}
+
+	actorID, _ := uuid.Parse(client.UserID)
+	if err := h.authorizer.AuthorizeDelete(ctx, msg, actorID, p.Scope); err != nil {
+		h.sendServiceError(client, req, err)
+		return
+	}
+
+	if err := h.messageService.Delete(ctx, msg, actorID, p.Scope); err != nil {
+		h.sendServiceError(client, req, err)
+		return
+	}
+
+	if p.Scope == model.DeleteScopeEveryone {
+		h.PublishDeletion(ctx, msg, actorID)
+	}
+}
+
+func (h *Hub) handleMarkRead(ctx context.Context, client *Client, req *model.WSMessage) {
+	var p model.MarkReadPayload
+	if !h.decode(client, req, &p) {
+		return
+	}
+	if !h.authorize(ctx, client, req, p.ConversationID) {
+		return
+	}
+
+	readerID, _ := uuid.Parse(client.UserID)
+	receipt, err := h.conversationService.MarkRead(ctx, p.ConversationID, readerID, p.MessageID)
+	if err != nil {
+		h.sendServiceError(client, req, err)
+		return
+	}
+
+	if receipt != nil {
+		h.BroadcastToConversation(p.ConversationID.String(), ReadReceiptEvent(receipt), nil)
+	}
+}
+
+func (h *Hub) handleReaction(ctx context.Context, client *Client, req *model.WSMessage, add bool) {
+	var p model.ReactionPayload
+	if !h.decode(client, req, &p) {
+		return
+	}
+
+	msg, err := h.messageService.GetByID(ctx, p.MessageID)
+	if err != nil {
+		h.sendServiceError(client, req, err)
+		return
+	}
+
+	if !h.permit(ctx, client, req, msg.ConversationID, model.PermReact) {
+		return
+	}
+
+	userID, _ := uuid.Parse(client.UserID)
+	changed, reactions, err := h.messageService.React(ctx, msg, userID, p.Emoji, add)
+	if err != nil {
+		h.sendServiceError(client, req, err)
+		return
+	}
+
+	if changed {
+		h.BroadcastToConversation(msg.ConversationID.String(), ReactionUpdatedEvent(msg, userID, p.Emoji, add, reactions), nil)
+	}
+}
+
+func (h *Hub) handleTyping(ctx context.Context, client *Client, req *model.WSMessage) {
+	var p model.TypingPayload
+	if !h.decode(client, req, &p) {
+		return
+	}
+	if !h.permit(ctx, client, req, p.ConversationID, model.PermPost) {
+		return
+	}
+
+	conversationID := p.ConversationID.String()
+	if p.IsTyping {
+		h.presenceService.StartTyping(ctx, conversationID, client.UserID)
+	} else {
+		h.presenceService.StopTyping(ctx, conversationID, client.UserID)
+	}
+
+	// Never trust the client-supplied user ID
+	p.UserID, _ = uuid.Parse(client.UserID)
+	h.BroadcastToConversation(conversationID, TypingEvent(&p), client)
+}
+
+// reply sends a direct response to a client frame, echoing its request ID.
+func (h *Hub) reply(client *Client, req *model.WSMessage, event *model.WSEvent) {
+	if req != nil {
+		event.ID = req.ID
+	}
+	h.sendToClient(client, event)
+}
+
+func (h *Hub) sendError(client *Client, req *model.WSMessage, code, message string, conversationID uuid.UUID) {
+	h.reply(client, req, ErrorEvent(code, message, conversationID))
+}
+
+func (h *Hub) sendServiceError(client *Client, req *model.WSMessage, err error) {
+	code, message := errorCode(err)
+	h.sendError(client, req, code, message, uuid.Nil)
+}
+
+// decode unmarshals the frame payload into v, replying with invalid_payload on failure.
+func (h *Hub) decode(client *Client, req *model.WSMessage, v interface{}) bool {
+	if len(req.Payload) == 0 {
+		h.sendError(client, req, model.ErrCodeInvalidPayload, "Missing payload", uuid.Nil)
+		return false
+	}
+	if err := json.Unmarshal(req.Payload, v); err != nil {
+		h.sendError(client, req, model.ErrCodeInvalidPayload, "Invalid payload: "+err.Error(), uuid.Nil)
+		return false
+	}
+	return true
+}
+
+// errorCode maps a service-layer error onto a machine-readable code and a
+// client-facing message.
+func errorCode(err error) (string, string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return model.ErrCodeNotFound, "Not found"
+	case errors.Is(err, service.ErrNotMember):
+		return model.ErrCodeNotMember, err.Error()
+	case errors.Is(err, service.ErrNotSender),
+		errors.Is(err, service.ErrForbidden),
+		errors.Is(err, service.ErrSystemMessage):
+		return model.ErrCodeForbidden, err.Error()
+	case errors.Is(err, service.ErrEditWindowExpired):
+		return model.ErrCodeEditWindowExpired, err.Error()
+	case errors.Is(err, service.ErrTooManyReactions):
+		return model.ErrCodeLimitExceeded, err.Error()
+	case errors.Is(err, service.ErrEmptyContent),
+		errors.Is(err, service.ErrInvalidScope),
+		errors.Is(err, service.ErrInvalidClientMsgID),
+		errors.Is(err, service.ErrInvalidEmoji),
+		errors.Is(err, service.ErrInvalidReference),
+		errors.Is(err, service.ErrMentionNotMember),
+		errors.Is(err, service.ErrInvalidAttachment):
+		return model.ErrCodeInvalidPayload, err.Error()
+	default:
+		log.Printf("Error handling client message: %v", err)
+		return model.ErrCodeInternal, "Internal error"
+	}
+}
+
+// checkPermission verifies the client's user belongs to the conversation and,
+// unless perm is empty, that their role grants perm. On failure it returns an
+// error code and message.
+func (h *Hub) checkPermission(ctx context.Context, client *Client, conversationID uuid.UUID, perm model.Permission) (string, string) {
+	if conversationID == uuid.Nil {
+		return model.ErrCodeInvalidPayload, "conversationId is required"
+	}
+
+	userID, err := uuid.Parse(client.UserID)
+	if err != nil {
+		return model.ErrCodeInvalidPayload, "Invalid user ID"
+	}
+
+	if perm == "" {
+		err = h.conversationService.RequireMember(ctx, conversationID, userID)
+	} else {
+		err = h.authorizer.Authorize(ctx, conversationID, userID, perm)
+	}
+	if err != nil {
+		return errorCode(err)
+	}
+
+	return "", ""
+}
+
+// authorize checks that the client's user belongs to the conversation and
+// replies with an error frame when it does not.
+func (h *Hub) authorize(ctx context.Context, client *Client, req *model.WSMessage, conversationID uuid.UUID) bool {
+	return h.permit(ctx, client, req, conversationID, "")
+}
+
+// permit is authorize for an action that needs perm.
+func (h *Hub) permit(ctx context.Context, client *Client, req *model.WSMessage, conversationID uuid.UUID, perm model.Permission) bool {
+	if code, message := h.checkPermission(ctx, client, conversationID, perm); code != "" {
+		h.sendError(client, req, code, message, conversationID)
+		return false
+	}
+	return true
+}
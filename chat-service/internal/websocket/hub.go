@@ -5,90 +5,158 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
-	"github.com/chatmenow/chat-service/internal/model"
 	"github.com/chatmenow/chat-service/internal/service"
 	"github.com/google/uuid"
 )
 
 type Hub struct {
-	clients         map[string]*Client          // userID -> Client
-	conversations   map[string]map[*Client]bool // conversationID -> set of clients
-	broadcast       chan *BroadcastMessage
-	register        chan *Client
-	unregister      chan *Client
-	mu              sync.RWMutex
-	messageService  *service.MessageService
-	presenceService *service.PresenceService
+	clients             map[string]map[*Client]bool // userID -> set of device connections
+	conversations       map[string]map[*Client]bool // conversationID -> set of clients
+	broadcaster         Broadcaster
+	register            chan *Client
+	unregister          chan *Client
+	mu                  sync.RWMutex
+	messageService      *service.MessageService
+	conversationService *service.ConversationService
+	presenceService     *service.PresenceService
+	authorizer          *service.Authorizer
 }
 
+// BroadcastMessage is the envelope handed to the Broadcaster. It must stay
+// JSON-serializable because it may travel between replicas. When UserIDs is
+// set the frame goes to every connection of those users instead of the room,
+// whether or not they have joined it.
 type BroadcastMessage struct {
-	ConversationID string
-	Message        interface{}
-	ExcludeClient  *Client
+	ConversationID   string          `json:"conversationId"`
+	Data             json.RawMessage `json:"data"`
+	ExcludeSessionID string          `json:"excludeSessionId,omitempty"`
+	UserIDs          []string        `json:"userIds,omitempty"`
+	MemberChange     *MemberChange   `json:"memberChange,omitempty"`
+	CloseRoom        bool            `json:"closeRoom,omitempty"`     // conversation deleted: drop the room after delivery
+	PolicyChanged    bool            `json:"policyChanged,omitempty"` // permission policy changed: drop cached access
 }
 
-type WSMessage struct {
-	Type    string                 `json:"type"`
-	Payload map[string]interface{} `json:"payload"`
+// MemberChange marks a frame announcing that a user joined, left or changed
+// role in the conversation. Every replica drops its cached member list; the
+// frame also reaches all of the user's connections, and if the user was
+// removed those connections leave the room afterwards.
+type MemberChange struct {
+	UserID  string `json:"userId"`
+	Removed bool   `json:"removed,omitempty"`
 }
 
-func NewHub(messageService *service.MessageService, presenceService *service.PresenceService) *Hub {
+func NewHub(
+	broadcaster Broadcaster,
+	messageService *service.MessageService,
+	conversationService *service.ConversationService,
+	presenceService *service.PresenceService,
+	authorizer *service.Authorizer,
+) *Hub {
 	return &Hub{
-		clients:         make(map[string]*Client),
-		conversations:   make(map[string]map[*Client]bool),
-		broadcast:       make(chan *BroadcastMessage, 256),
-		register:        make(chan *Client),
-		unregister:      make(chan *Client),
-		messageService:  messageService,
-		presenceService: presenceService,
+		clients:             make(map[string]map[*Client]bool),
+		conversations:       make(map[string]map[*Client]bool),
+		broadcaster:         broadcaster,
+		register:            make(chan *Client),
+		unregister:          make(chan *Client),
+		messageService:      messageService,
+		conversationService: conversationService,
+		presenceService:     presenceService,
+		authorizer:          authorizer,
 	}
 }
 
 func (h *Hub) Run() {
+	// Presence sessions expire unless refreshed, so a crashed replica's
+	// users drop offline on their own
+	presence := time.NewTicker(pingPeriod)
+	defer presence.Stop()
+
 	for {
 		select {
+		case <-presence.C:
+			go h.refreshPresence()
+
 		case client := <-h.register:
 			h.registerClient(client)
 
 		case client := <-h.unregister:
 			h.unregisterClient(client)
 
-		case msg := <-h.broadcast:
-			h.broadcastToConversation(msg)
+		case msg := <-h.broadcaster.Messages():
+			h.deliver(msg)
 		}
 	}
 }
 
+// refreshPresence extends the presence sessions of every client connected to
+// this replica.
+func (h *Hub) refreshPresence() {
+	h.mu.RLock()
+	sessions := make(map[string][]string, len(h.clients))
+	for userID, devices := range h.clients {
+		for client := range devices {
+			sessions[userID] = append(sessions[userID], client.SessionID)
+		}
+	}
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	if err := h.presenceService.Refresh(ctx, sessions); err != nil {
+		log.Printf("Error refreshing presence: %v", err)
+	}
+}
+
 func (h *Hub) registerClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	h.clients[client.UserID] = client
+	devices := h.clients[client.UserID]
+	if devices == nil {
+		devices = make(map[*Client]bool)
+		h.clients[client.UserID] = devices
+	}
+	devices[client] = true
+
 	ctx := context.Background()
-	h.presenceService.SetOnline(ctx, client.UserID)
+	h.presenceService.SetOnline(ctx, client.UserID, client.SessionID)
 
-	log.Printf("Client registered: %s", client.UserID)
+	log.Printf("Client registered: %s (device %s, session %s, %d active)",
+		client.UserID, client.DeviceID, client.SessionID, len(devices))
 }
 
 func (h *Hub) unregisterClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if _, ok := h.clients[client.UserID]; ok {
-		delete(h.clients, client.UserID)
-		close(client.Send)
+	devices, ok := h.clients[client.UserID]
+	if !ok || !devices[client] {
+		return
+	}
 
-		// Remove from all conversations
-		for _, clients := range h.conversations {
-			delete(clients, client)
-		}
+	delete(devices, client)
+	close(client.Send)
 
-		ctx := context.Background()
-		h.presenceService.SetOffline(ctx, client.UserID)
+	// Remove from all conversations
+	for conversationID, clients := range h.conversations {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.conversations, conversationID)
+		}
+	}
 
-		log.Printf("Client unregistered: %s", client.UserID)
+	if len(devices) == 0 {
+		delete(h.clients, client.UserID)
 	}
+
+	// The user stays online until their last session, on any replica, disconnects
+	ctx := context.Background()
+	h.presenceService.SetOffline(ctx, client.UserID, client.SessionID)
+
+	log.Printf("Client unregistered: %s (device %s, session %s, %d active)",
+		client.UserID, client.DeviceID, client.SessionID, len(devices))
 }
 
 func (h *Hub) JoinConversation(client *Client, conversationID string) {
@@ -109,130 +177,156 @@ func (h *Hub) LeaveConversation(client *Client, conversationID string) {
 
 	if clients, ok := h.conversations[conversationID]; ok {
 		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.conversations, conversationID)
+		}
 	}
 }
 
+// BroadcastToConversation publishes a frame to every client in the
+// conversation, on this and every other replica.
 func (h *Hub) BroadcastToConversation(conversationID string, message interface{}, excludeClient *Client) {
-	h.broadcast <- &BroadcastMessage{
-		ConversationID: conversationID,
-		Message:        message,
-		ExcludeClient:  excludeClient,
-	}
+	h.publish(&BroadcastMessage{ConversationID: conversationID}, message, excludeClient)
 }
 
-func (h *Hub) broadcastToConversation(msg *BroadcastMessage) {
-	h.mu.RLock()
-	clients := h.conversations[msg.ConversationID]
-	h.mu.RUnlock()
-
-	if clients == nil {
+// SendToUsers publishes a frame to every connection of the given users, on
+// this and every other replica, regardless of the rooms they have joined.
+// conversationID is the conversation the frame is about.
+func (h *Hub) SendToUsers(conversationID string, userIDs []string, message interface{}) {
+	if len(userIDs) == 0 {
 		return
 	}
+	h.publish(&BroadcastMessage{ConversationID: conversationID, UserIDs: userIDs}, message, nil)
+}
+
+// BroadcastMemberChange publishes a membership frame to the conversation and
+// to every connection of the affected user. When removed is true, the user's
+// connections are dropped from the room once the frame is delivered.
+func (h *Hub) BroadcastMemberChange(conversationID, userID string, message interface{}, removed bool) {
+	h.publish(&BroadcastMessage{
+		ConversationID: conversationID,
+		MemberChange:   &MemberChange{UserID: userID, Removed: removed},
+	}, message, nil)
+}
+
+// CloseConversation sends a final frame to every connection of the given
+// users, then drops the conversation's room and cached members on every replica.
+func (h *Hub) CloseConversation(conversationID string, userIDs []string, message interface{}) {
+	h.publish(&BroadcastMessage{ConversationID: conversationID, UserIDs: userIDs, CloseRoom: true}, message, nil)
+}
+
+// BroadcastPolicyChange sends a frame to every connection of the given users
+// and drops the conversation's cached permission policy on every replica.
+func (h *Hub) BroadcastPolicyChange(conversationID string, userIDs []string, message interface{}) {
+	h.publish(&BroadcastMessage{ConversationID: conversationID, UserIDs: userIDs, PolicyChanged: true}, message, nil)
+}
 
-	data, err := json.Marshal(msg.Message)
+func (h *Hub) publish(msg *BroadcastMessage, message interface{}, excludeClient *Client) {
+	data, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Error marshaling message: %v", err)
 		return
 	}
 
-	for client := range clients {
-		if msg.ExcludeClient != nil && client == msg.ExcludeClient {
-			continue
-		}
+	msg.Data = data
+	if excludeClient != nil {
+		msg.ExcludeSessionID = excludeClient.SessionID
+	}
 
-		select {
-		case client.Send <- data:
-		default:
-			close(client.Send)
-			delete(clients, client)
-		}
+	if err := h.broadcaster.Publish(context.Background(), msg); err != nil {
+		log.Printf("Error publishing to conversation %s: %v", msg.ConversationID, err)
 	}
 }
 
-func (h *Hub) HandleClientMessage(client *Client, messageData []byte) {
-	var wsMsg WSMessage
-	if err := json.Unmarshal(messageData, &wsMsg); err != nil {
-		log.Printf("Error parsing message: %v", err)
-		return
-	}
+// deliver hands a published frame to the local clients it targets.
+func (h *Hub) deliver(msg *BroadcastMessage) {
+	data := []byte(msg.Data)
 
-	ctx := context.Background()
+	if msg.MemberChange != nil || msg.CloseRoom || msg.PolicyChanged {
+		if conversationID, err := uuid.Parse(msg.ConversationID); err == nil {
+			h.conversationService.ForgetMembers(conversationID)
+		}
+	}
 
-	switch wsMsg.Type {
-	case "join_conversation":
-		conversationID, ok := wsMsg.Payload["conversationId"].(string)
-		if !ok {
-			return
+	h.mu.RLock()
+	var targets []*Client
+	seen := make(map[*Client]bool)
+	add := func(clients map[*Client]bool) {
+		for client := range clients {
+			if seen[client] || (msg.ExcludeSessionID != "" && client.SessionID == msg.ExcludeSessionID) {
+				continue
+			}
+			seen[client] = true
+			targets = append(targets, client)
 		}
-		h.JoinConversation(client, conversationID)
+	}
+	if len(msg.UserIDs) > 0 {
+		for _, userID := range msg.UserIDs {
+			add(h.clients[userID])
+		}
+	} else {
+		add(h.conversations[msg.ConversationID])
+	}
+	if msg.MemberChange != nil {
+		add(h.clients[msg.MemberChange.UserID])
+	}
+	h.mu.RUnlock()
 
-	case "leave_conversation":
-		conversationID, ok := wsMsg.Payload["conversationId"].(string)
-		if !ok {
-			return
+	for _, client := range targets {
+		select {
+		case client.Send <- data:
+		default:
+			// Slow consumer: drop this device only, the user's other devices keep going
+			h.unregisterClient(client)
 		}
-		h.LeaveConversation(client, conversationID)
+	}
 
-	case "send_message":
-		conversationIDStr, _ := wsMsg.Payload["conversationId"].(string)
-		content, _ := wsMsg.Payload["content"].(string)
+	if msg.MemberChange != nil && msg.MemberChange.Removed {
+		h.removeFromConversation(msg.ConversationID, msg.MemberChange.UserID)
+	}
+	if msg.CloseRoom {
+		h.mu.Lock()
+		delete(h.conversations, msg.ConversationID)
+		h.mu.Unlock()
+	}
+}
 
-		// Parse UUIDs
-		conversationID, err := uuid.Parse(conversationIDStr)
-		if err != nil {
-			log.Printf("Invalid conversation ID: %v", err)
-			return
-		}
+// removeFromConversation drops every local connection of userID from the room.
+func (h *Hub) removeFromConversation(conversationID, userID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-		senderID, err := uuid.Parse(client.UserID)
-		if err != nil {
-			log.Printf("Invalid user ID: %v", err)
-			return
-		}
+	clients, ok := h.conversations[conversationID]
+	if !ok {
+		return
+	}
+	for client := range h.clients[userID] {
+		delete(clients, client)
+	}
+	if len(clients) == 0 {
+		delete(h.conversations, conversationID)
+	}
+}
 
-		msg := &model.Message{
-			ConversationID: conversationID,
-			SenderID:       senderID,
-			Content:        content,
-			Type:           "text",
-		}
+// sendToClient queues a frame for a single connection. It is a no-op if the
+// client has already been unregistered.
+func (h *Hub) sendToClient(client *Client, message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
 
-		if err := h.messageService.Create(ctx, msg); err != nil {
-			log.Printf("Error saving message: %v", err)
-			return
-		}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-		// Broadcast to conversation
-		h.BroadcastToConversation(conversationIDStr, map[string]interface{}{
-			"type": "new_message",
-			"payload": map[string]interface{}{
-				"id":             msg.ID,
-				"conversationId": msg.ConversationID,
-				"senderId":       msg.SenderID,
-				"content":        msg.Content,
-				"type":           msg.Type,
-				"createdAt":      msg.CreatedAt,
-			},
-		}, nil)
-
-	case "typing":
-		conversationID, _ := wsMsg.Payload["conversationId"].(string)
-		isTyping, _ := wsMsg.Payload["isTyping"].(bool)
-
-		if isTyping {
-			h.presenceService.StartTyping(ctx, conversationID, client.UserID)
-		} else {
-			h.presenceService.StopTyping(ctx, conversationID, client.UserID)
-		}
+	if !h.clients[client.UserID][client] {
+		return
+	}
 
-		// Broadcast typing status
-		h.BroadcastToConversation(conversationID, map[string]interface{}{
-			"type": "user_typing",
-			"payload": map[string]interface{}{
-				"conversationId": conversationID,
-				"userId":         client.UserID,
-				"isTyping":       isTyping,
-			},
-		}, client)
+	select {
+	case client.Send <- data:
+	default:
+		log.Printf("Dropping frame for slow client %s (session %s)", client.UserID, client.SessionID)
 	}
 }
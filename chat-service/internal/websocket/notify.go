@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"context"
+	"log"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// PublishMessage announces a newly stored message. Mentioned users get a
+// mentioned frame on all their connections, even if they haven't joined the
+// room. Main-timeline messages go to the room as new_message. Thread replies go
+// to the thread's participants as thread_reply, and the room only gets a
+// thread_updated counter so that a busy thread does not flood it. Direct
+// frames respect each recipient's mute and notification level.
+func (h *Hub) PublishMessage(ctx context.Context, msg *model.Message) {
+	if len(msg.Mentions) > 0 {
+		h.notifyMentions(ctx, msg)
+	}
+
+	conversationID := msg.ConversationID.String()
+	if msg.ThreadRootID == nil {
+		h.BroadcastToConversation(conversationID, NewMessageEvent(msg), nil)
+		return
+	}
+
+	root, err := h.messageService.GetByID(ctx, *msg.ThreadRootID)
+	if err != nil {
+		log.Printf("Error loading thread root %s: %v", *msg.ThreadRootID, err)
+		return
+	}
+	h.BroadcastToConversation(conversationID, ThreadUpdatedEvent(root), nil)
+
+	participants, err := h.messageService.GetThreadParticipants(ctx, root)
+	if err != nil {
+		log.Printf("Error loading participants of thread %s: %v", root.ID, err)
+		return
+	}
+
+	// Participants who have since left the conversation are not notified
+	members := make([]uuid.UUID, 0, len(participants))
+	for _, userID := range participants {
+		if ok, err := h.conversationService.IsMember(ctx, msg.ConversationID, userID); err == nil && ok {
+			members = append(members, userID)
+		}
+	}
+	h.notify(ctx, msg.ConversationID, members, false, ThreadReplyEvent(msg))
+}
+
+// PublishDeletion broadcasts that actorID deleted msg for everyone. Deleting a
+// thread reply also refreshes the thread's counter in the room.
+func (h *Hub) PublishDeletion(ctx context.Context, msg *model.Message, actorID uuid.UUID) {
+	conversationID := msg.ConversationID.String()
+	h.BroadcastToConversation(conversationID, MessageDeletedEvent(msg, actorID), nil)
+
+	if msg.ThreadRootID == nil {
+		return
+	}
+	root, err := h.messageService.GetByID(ctx, *msg.ThreadRootID)
+	if err != nil {
+		log.Printf("Error loading thread root %s: %v", *msg.ThreadRootID, err)
+		return
+	}
+	h.BroadcastToConversation(conversationID, ThreadUpdatedEvent(root), nil)
+}
+
+// notifyMentions sends a mentioned frame to every user the message mentions.
+func (h *Hub) notifyMentions(ctx context.Context, msg *model.Message) {
+	mentioned, err := h.messageService.GetMentionedUsers(ctx, msg.ID)
+	if err != nil {
+		log.Printf("Error loading mentions of message %s: %v", msg.ID, err)
+		return
+	}
+
+	h.notify(ctx, msg.ConversationID, mentioned, true, MentionedEvent(msg))
+}
+
+// notify sends a direct frame to those of userIDs who haven't muted the
+// conversation or turned its notifications down; mention tells whether the
+// frame is about a mention.
+func (h *Hub) notify(ctx context.Context, conversationID uuid.UUID, userIDs []uuid.UUID, mention bool, event *model.WSEvent) {
+	notifiable, err := h.conversationService.Notifiable(ctx, conversationID, userIDs, mention)
+	if err != nil {
+		log.Printf("Error loading notification settings for %s: %v", conversationID, err)
+		return
+	}
+
+	ids := make([]string, len(notifiable))
+	for i, userID := range notifiable {
+		ids[i] = userID.String()
+	}
+	h.SendToUsers(conversationID.String(), ids, event)
+}
@@ -0,0 +1,41 @@
+package websocket
+
+import "time"
+
+const (
+	clientFrameRate  = 10 // frames per second, sustained
+	clientFrameBurst = 30
+)
+
+// rateLimiter is a token bucket for frames from a single client. It is only
+// used from the client's ReadPump goroutine and needs no locking.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (l *rateLimiter) allow() bool {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
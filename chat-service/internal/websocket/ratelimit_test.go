@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// drain counts the frames allowed right now.
+func drain(l *rateLimiter) int {
+	n := 0
+	for l.allow() {
+		n++
+	}
+	return n
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := newRateLimiter(10, 30)
+
+	if n := drain(l); n != 30 {
+		t.Fatalf("burst allowed %d frames, want 30", n)
+	}
+	if l.allow() {
+		t.Fatal("frame allowed after the burst was spent")
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	l := newRateLimiter(10, 30)
+	drain(l)
+
+	// Pretend a second has passed
+	l.last = l.last.Add(-time.Second)
+	if n := drain(l); n != 10 {
+		t.Errorf("after 1s allowed %d frames, want 10", n)
+	}
+}
+
+func TestRateLimiterCapsAtBurst(t *testing.T) {
+	l := newRateLimiter(10, 30)
+	drain(l)
+
+	l.last = l.last.Add(-time.Hour)
+	if n := drain(l); n != 30 {
+		t.Errorf("after an idle hour allowed %d frames, want 30", n)
+	}
+}
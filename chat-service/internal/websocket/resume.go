@@ -0,0 +1,159 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/chatmenow/chat-service/internal/model"
+	"github.com/chatmenow/chat-service/internal/service"
+	"github.com/google/uuid"
+)
+
+// resumeLimit caps how many new and how many changed messages are replayed
+// per conversation. Beyond that the client is told to resync through the REST
+// history API.
+const resumeLimit = 200
+
+// maxResumeConversations caps how many conversations one resume frame can
+// replay; the rest are reported in a resync_required frame.
+const maxResumeConversations = 50
+
+// handleResume rejoins the conversations a reconnecting client was in and
+// replays what it missed from storage. The client is joined before the replay
+// so nothing published meanwhile is lost; clients drop duplicates by seq.
+func (h *Hub) handleResume(ctx context.Context, client *Client, req *model.WSMessage) {
+	var p model.ResumePayload
+	if !h.decode(client, req, &p) {
+		return
+	}
+
+	userID, _ := uuid.Parse(client.UserID)
+
+	entries := p.Conversations
+	var skipped []uuid.UUID
+	if len(entries) > maxResumeConversations {
+		for _, entry := range entries[maxResumeConversations:] {
+			skipped = append(skipped, entry.ConversationID)
+		}
+		entries = entries[:maxResumeConversations]
+	}
+
+	for _, entry := range entries {
+		conversationID := entry.ConversationID
+		if !h.authorize(ctx, client, req, conversationID) {
+			continue
+		}
+		h.JoinConversation(client, conversationID.String())
+
+		missed, err := h.messageService.GetMissed(ctx, conversationID, userID, entry.LastSeq, p.Since, resumeLimit)
+		if err != nil {
+			log.Printf("Error loading missed messages for %s: %v", conversationID, err)
+			h.sendError(client, req, model.ErrCodeInternal, "Could not resume conversation", conversationID)
+			continue
+		}
+
+		if missed.ResyncRequired {
+			resync := ResyncRequiredEvent([]uuid.UUID{conversationID})
+			resync.ID = req.ID
+			if !h.sendToClientWait(client, resync) {
+				return
+			}
+			continue
+		}
+
+		if !h.replay(ctx, client, conversationID, missed) {
+			return
+		}
+
+		// truncated tells the client to page the rest in via GET /conversations/{id}/messages?after=
+		resumed := ResumedEvent(conversationID, missed.Truncated)
+		resumed.ID = req.ID
+		h.sendToClientWait(client, resumed)
+	}
+
+	if len(skipped) > 0 {
+		resync := ResyncRequiredEvent(skipped)
+		resync.ID = req.ID
+		h.sendToClientWait(client, resync)
+	}
+}
+
+// replay sends the missed events for one conversation in seq order, followed
+// by read receipts. It returns false if the client went away.
+func (h *Hub) replay(ctx context.Context, client *Client, conversationID uuid.UUID, missed *service.MissedMessages) bool {
+	for i := range missed.Changed {
+		msg := &missed.Changed[i]
+		event := MessageUpdatedEvent(msg)
+		if msg.Deleted {
+			event = MessageDeletedEvent(msg, uuid.Nil)
+		}
+		if !h.sendToClientWait(client, event) {
+			return false
+		}
+	}
+
+	for i := range missed.New {
+		msg := &missed.New[i]
+		event := NewMessageEvent(msg)
+		if msg.ThreadRootID != nil {
+			event = ThreadReplyEvent(msg)
+		}
+		if msg.Deleted {
+			event = MessageDeletedEvent(msg, uuid.Nil)
+		}
+		if !h.sendToClientWait(client, event) {
+			return false
+		}
+	}
+
+	if missed.Since == nil {
+		return true
+	}
+
+	receipts, err := h.conversationService.GetReadReceiptsSince(ctx, conversationID, *missed.Since)
+	if err != nil {
+		log.Printf("Error loading read receipts for %s: %v", conversationID, err)
+		return true
+	}
+	for i := range receipts {
+		if !h.sendToClientWait(client, ReadReceiptEvent(&receipts[i])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sendToClientWait is like sendToClient but waits for room in the client's
+// queue instead of dropping the frame, for up to writeWait. The hub lock is not
+// held while waiting.
+func (h *Hub) sendToClientWait(client *Client, message interface{}) bool {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return true
+	}
+
+	deadline := time.Now().Add(writeWait)
+	for {
+		h.mu.RLock()
+		if !h.clients[client.UserID][client] {
+			h.mu.RUnlock()
+			return false
+		}
+		select {
+		case client.Send <- data:
+			h.mu.RUnlock()
+			return true
+		default:
+		}
+		h.mu.RUnlock()
+
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
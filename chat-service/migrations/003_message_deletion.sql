@@ -0,0 +1,9 @@
+-- Per-user hidden messages ("delete for me")
+CREATE TABLE IF NOT EXISTS hidden_messages (
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX idx_hidden_messages_user_id ON hidden_messages(user_id);
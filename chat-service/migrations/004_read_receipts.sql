@@ -0,0 +1,3 @@
+-- Per-member read pointer
+ALTER TABLE conversation_members ADD COLUMN IF NOT EXISTS last_read_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE conversation_members ADD COLUMN IF NOT EXISTS last_read_at TIMESTAMP WITH TIME ZONE;
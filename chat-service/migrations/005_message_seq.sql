@@ -0,0 +1,17 @@
+-- Per-conversation gap-free message sequence numbers
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS last_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS seq BIGINT NOT NULL DEFAULT 0;
+
+-- Backfill existing history in creation order
+UPDATE messages m
+SET seq = o.rn
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS rn
+    FROM messages
+) o
+WHERE m.id = o.id;
+
+UPDATE conversations c
+SET last_seq = COALESCE((SELECT MAX(seq) FROM messages m WHERE m.conversation_id = c.id), 0);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_conversation_seq ON messages(conversation_id, seq);
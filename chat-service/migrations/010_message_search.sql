@@ -0,0 +1,3 @@
+-- Full-text search over message content
+CREATE INDEX IF NOT EXISTS idx_messages_content_fts
+    ON messages USING GIN (to_tsvector('simple', content));
@@ -0,0 +1,4 @@
+-- Server-generated system messages (membership changes, ...)
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_type_check
+    CHECK (type IN ('text', 'image', 'file', 'video', 'system'));
@@ -0,0 +1,2 @@
+-- Conversation description
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS description VARCHAR(1000);
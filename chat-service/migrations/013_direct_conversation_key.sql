@@ -0,0 +1,26 @@
+-- At most one live direct conversation per pair of users
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS direct_key VARCHAR(73);
+
+-- Backfill: key the oldest existing direct conversation of each pair; any
+-- later duplicates keep a NULL key and stay accessible as they are.
+WITH pairs AS (
+    SELECT c.id, c.created_at,
+           string_agg(cm.user_id::text, ':' ORDER BY cm.user_id::text) AS pair_key
+    FROM conversations c
+    JOIN conversation_members cm ON cm.conversation_id = c.id AND cm.deleted_at IS NULL
+    WHERE c.type = 'direct' AND c.deleted_at IS NULL AND c.direct_key IS NULL
+    GROUP BY c.id, c.created_at
+    HAVING COUNT(*) = 2
+),
+ranked AS (
+    SELECT id, pair_key, ROW_NUMBER() OVER (PARTITION BY pair_key ORDER BY created_at, id) AS rn
+    FROM pairs
+)
+UPDATE conversations c
+SET direct_key = ranked.pair_key
+FROM ranked
+WHERE c.id = ranked.id AND ranked.rn = 1
+    AND NOT EXISTS (SELECT 1 FROM conversations k WHERE k.direct_key = ranked.pair_key AND k.deleted_at IS NULL);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_direct_key
+    ON conversations(direct_key) WHERE deleted_at IS NULL;
@@ -0,0 +1,34 @@
+-- Role hierarchy (owner > admin > moderator > member > readonly) and
+-- per-conversation permission overrides
+ALTER TABLE conversation_members DROP CONSTRAINT IF EXISTS conversation_members_role_check;
+ALTER TABLE conversation_members ADD CONSTRAINT conversation_members_role_check
+    CHECK (role IN ('owner', 'admin', 'moderator', 'member', 'readonly'));
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS permissions JSONB;
+
+-- Both people in a direct conversation are equals
+UPDATE conversation_members cm
+SET role = 'member'
+FROM conversations c
+WHERE c.id = cm.conversation_id AND c.type = 'direct' AND cm.role <> 'member';
+
+-- Every group gets an owner: its creator if still a member, otherwise the
+-- longest-standing admin, otherwise the longest-standing member
+WITH candidates AS (
+    SELECT cm.id,
+           ROW_NUMBER() OVER (
+               PARTITION BY cm.conversation_id
+               ORDER BY (cm.user_id = c.created_by) DESC, (cm.role = 'admin') DESC, cm.joined_at, cm.id
+           ) AS rn
+    FROM conversation_members cm
+    JOIN conversations c ON c.id = cm.conversation_id
+    WHERE c.type = 'group' AND c.deleted_at IS NULL AND cm.deleted_at IS NULL
+        AND NOT EXISTS (
+            SELECT 1 FROM conversation_members o
+            WHERE o.conversation_id = cm.conversation_id AND o.role = 'owner' AND o.deleted_at IS NULL
+        )
+)
+UPDATE conversation_members cm
+SET role = 'owner'
+FROM candidates
+WHERE cm.id = candidates.id AND candidates.rn = 1;
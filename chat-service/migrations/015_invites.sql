@@ -0,0 +1,32 @@
+-- Invite links and join requests for group conversations
+CREATE TABLE IF NOT EXISTS conversation_invites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    token VARCHAR(64) NOT NULL,
+    created_by UUID NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    max_uses INTEGER NOT NULL DEFAULT 0, -- 0 = unlimited
+    uses INTEGER NOT NULL DEFAULT 0,
+    requires_approval BOOLEAN NOT NULL DEFAULT FALSE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_invites_token ON conversation_invites(token);
+CREATE INDEX IF NOT EXISTS idx_conversation_invites_conversation_id ON conversation_invites(conversation_id);
+
+CREATE TABLE IF NOT EXISTS join_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    invite_id UUID NOT NULL REFERENCES conversation_invites(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    decided_by UUID,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_join_requests_conversation_id ON join_requests(conversation_id);
+-- At most one pending request per user and conversation
+CREATE UNIQUE INDEX IF NOT EXISTS idx_join_requests_pending
+    ON join_requests(conversation_id, user_id) WHERE status = 'pending';
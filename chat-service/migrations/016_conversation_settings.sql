@@ -0,0 +1,15 @@
+-- Per-member conversation settings: mute, pin, archive, notifications, nickname
+CREATE TABLE IF NOT EXISTS conversation_settings (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    muted BOOLEAN NOT NULL DEFAULT FALSE,
+    muted_until TIMESTAMP WITH TIME ZONE, -- NULL while muted = until unmuted
+    pinned_at TIMESTAMP WITH TIME ZONE,
+    archived_at TIMESTAMP WITH TIME ZONE,
+    notification_level VARCHAR(20) NOT NULL DEFAULT 'all' CHECK (notification_level IN ('all', 'mentions', 'none')),
+    nickname VARCHAR(100),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_settings_user_id ON conversation_settings(user_id);
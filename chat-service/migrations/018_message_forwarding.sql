@@ -0,0 +1,2 @@
+-- Provenance of forwarded messages
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS forwarded_from JSONB;
@@ -0,0 +1,20 @@
+-- Files uploaded to conversations; the blobs live in the configured store
+CREATE TABLE IF NOT EXISTS attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    uploader_id UUID NOT NULL,
+    message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(512) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_conversation_id ON attachments(conversation_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_uploader_id ON attachments(uploader_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_message_id ON attachments(message_id);
+
+-- Messages reference attachments by ID; the GIN index serves access checks
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS attachment_ids JSONB;
+CREATE INDEX IF NOT EXISTS idx_messages_attachment_ids ON messages USING GIN (attachment_ids);
@@ -0,0 +1,5 @@
+-- Deleting a message for everyone now removes its pins; drop the pins left
+-- behind by earlier deletions so they stop counting toward the pin limit
+DELETE FROM pinned_messages p
+USING messages m
+WHERE m.id = p.message_id AND m.deleted_at IS NOT NULL;